@@ -0,0 +1,339 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"tfsw/internal/utils"
+)
+
+var (
+	bundleCmd = &cobra.Command{
+		Long:  "Package releases into a single archive on a connected machine, then install from it on a disconnected one. Everything in a bundle is verified when it's made and again when it's installed",
+		Short: "Manage offline bundles",
+		Use:   "bundle",
+	}
+	bundleExportCmd = &cobra.Command{
+		Args:  cobra.MinimumNArgs(1),
+		Long:  "Downloads and verifies the release zips for the given versions, operating systems and architectures, then writes them to a tar archive with their SHA256SUMS, signatures and release index. Versions are resolved the same way as for new",
+		Run:   bundleExportRun,
+		Short: "Package releases into a bundle",
+		Use:   "export VERSION...",
+	}
+	bundleImportCmd = &cobra.Command{
+		Args:  cobra.MinimumNArgs(1),
+		Long:  "Installs versions from a bundle made with export, checking the signature of each SHA256SUMS and the checksum of each zip. Without a version, every version in the bundle for this machine is installed",
+		Run:   bundleImportRun,
+		Short: "Install releases from a bundle",
+		Use:   "import BUNDLE [VERSION...]",
+	}
+
+	// NOTE: A bundle holds a zip of around 30MB for each version, OS and
+	// architecture, plus a handful of small files
+	bundleArchiveLimits = utils.ArchiveLimits{
+		MaxBytes:   16 << 30,
+		MaxEntries: 4096,
+	}
+)
+
+func init() {
+	// Adds bundle as a child command of tfsw, with its own child commands
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleExportCmd)
+	bundleCmd.AddCommand(bundleImportCmd)
+
+	// Add any extra command line flags for bundle here
+	bundleExportCmd.Flags().StringSlice("arch", []string{runtime.GOARCH}, "Architectures to include")
+	bundleExportCmd.Flags().StringSlice("os", []string{runtime.GOOS}, "Operating systems to include")
+	bundleExportCmd.Flags().StringP("output", "o", "tfsw-bundle.tar", "File to write the bundle to")
+}
+
+// bundleExportRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `bundle export` command
+func bundleExportRun(cmd *cobra.Command, args []string) {
+	goos, _ := cmd.Flags().GetStringSlice("os")
+	goarch, _ := cmd.Flags().GetStringSlice("arch")
+	output, _ := cmd.Flags().GetString("output")
+
+	var vers versions
+	for _, arg := range args {
+		ver, err := resolveVersion(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to resolve %s: %v\n", arg, err)
+			os.Exit(1)
+		}
+
+		if !vers.contains(ver) {
+			vers = append(vers, ver)
+		}
+	}
+	sort.Sort(vers)
+
+	n, err := exportBundle(vers, goos, goarch, output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to export bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Bundle of %d release archives written to %s\n", n, output)
+	os.Exit(0)
+}
+
+// bundleImportRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `bundle import` command
+func bundleImportRun(cmd *cobra.Command, args []string) {
+	os.MkdirAll(config.CacheDirectory, 0755)
+
+	dir, err := os.MkdirTemp(config.CacheDirectory, "bundle-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to import %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	vers, err := openBundle(args[0], dir, args[1:])
+	if err != nil {
+		os.RemoveAll(dir)
+		fmt.Fprintf(os.Stderr, "Unable to import %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	var added, existing, failed int
+	for _, ver := range vers {
+		err := installVersion(ver, dirSource{dir: dir})
+		msg, ok := newOutcome(ver, err)
+		switch {
+		case !ok:
+			fmt.Fprintln(os.Stderr, msg)
+			failed++
+		case err == nil:
+			fmt.Println(msg)
+			added++
+		default:
+			fmt.Println(msg)
+			existing++
+		}
+	}
+
+	os.RemoveAll(dir)
+
+	fmt.Printf("%d added, %d already existed, %d failed\n", added, existing, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// exportBundle fetches and verifies the release zips for every version,
+// OS and architecture, then writes them to a tar archive at output laid
+// out like the repository, so it can be installed from with a dirSource.
+// It returns the number of release zips in the bundle
+func exportBundle(vers versions, goos, goarch []string, output string) (int, error) {
+	if err := os.MkdirAll(config.CacheDirectory, 0755); err != nil {
+		return 0, err
+	}
+
+	dir, err := os.MkdirTemp(config.CacheDirectory, "bundle-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	p := config.Product
+	idx := &releaseIndex{Name: p.Name, Versions: map[string]release{}}
+	src := releaseSource()
+
+	var n int = 0
+	for _, v := range vers {
+		ver := v.String()
+		r := release{
+			Name:    p.Name,
+			Shasums: p.sumsName(ver),
+			Version: ver,
+		}
+
+		var zips []string
+		for _, o := range goos {
+			for _, a := range goarch {
+				zip := p.archiveName(ver, o, a)
+				zips = append(zips, zip)
+				r.Builds = append(r.Builds, build{Arch: a, Filename: zip, Name: p.Name, OS: o, Version: ver})
+			}
+		}
+
+		fmt.Printf("Fetching %s %s for %s\n", p.Title, ver, strings.Join(platforms(goos, goarch), ", "))
+
+		vdir := filepath.Join(dir, p.Name, ver)
+		if err := os.MkdirAll(vdir, 0755); err != nil {
+			return 0, err
+		}
+
+		if err := fetchRelease(src, ver, vdir, zips...); err != nil {
+			return 0, fmt.Errorf("%s %s: %w", p.Title, ver, err)
+		}
+
+		sigs, err := filepath.Glob(filepath.Join(vdir, r.Shasums+"*"+p.SignatureSuffix))
+		if err != nil {
+			return 0, err
+		}
+
+		for _, sig := range sigs {
+			r.ShasumsSignatures = append(r.ShasumsSignatures, filepath.Base(sig))
+		}
+		r.ShasumsSignature = r.Shasums + p.SignatureSuffix
+
+		idx.Versions[ver] = r
+		n += len(zips)
+	}
+
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, p.Name, "index.json"), b, 0644); err != nil {
+		return 0, err
+	}
+
+	if _, err := utils.Tar(dir, output); err != nil {
+		os.Remove(output)
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// openBundle extracts a bundle into dir and returns the versions in it to
+// install on this machine. The product is switched to the one the bundle
+// was made for. Each expression is resolved against the versions in the
+// bundle, and without any every version is returned
+func openBundle(bundle, dir string, exprs []string) (versions, error) {
+	if _, err := utils.Untar(bundle, dir, bundleArchiveLimits); err != nil {
+		return nil, err
+	}
+
+	name, err := bundleProduct(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != config.Product.Name {
+		config.ProductName = name
+		if err := config.loadProduct(); err != nil {
+			return nil, err
+		}
+	}
+
+	idx, err := localIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	av := idx.available()
+	if len(av) == 0 {
+		return nil, fmt.Errorf("%w for %s/%s in the bundle", ErrNoneAvailable, runtime.GOOS, runtime.GOARCH)
+	}
+
+	if len(exprs) == 0 {
+		return av, nil
+	}
+
+	var vers versions
+	for _, expr := range exprs {
+		match, err := versionMatcher(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		ver, err := pickMatch(av, match, expr)
+		if err != nil {
+			return nil, err
+		}
+
+		if !vers.contains(ver) {
+			vers = append(vers, ver)
+		}
+	}
+
+	return vers, nil
+}
+
+// bundleProduct returns the name of the product an extracted bundle was
+// made for, from the index.json export writes alongside its releases
+func bundleProduct(dir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "index.json"))
+	if err != nil {
+		return "", err
+	}
+
+	switch len(files) {
+	case 0:
+		return "", errors.New("the bundle has no index.json, it wasn't made with bundle export")
+	case 1:
+	default:
+		return "", errors.New("the bundle has more than one index.json, it wasn't made with bundle export")
+	}
+
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		return "", err
+	}
+
+	var idx struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return "", fmt.Errorf("%s: %v", files[0], err)
+	}
+
+	// NOTE: Bundles are laid out like the repository, so the directory
+	// holding the index is named after the product too
+	if idx.Name == "" {
+		idx.Name = filepath.Base(filepath.Dir(files[0]))
+	}
+
+	p, err := lookupProduct(idx.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return p.Name, nil
+}
+
+// platforms returns every OS and architecture pair as os/arch
+func platforms(goos, goarch []string) []string {
+	var p []string
+	for _, o := range goos {
+		for _, a := range goarch {
+			p = append(p, o+"/"+a)
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,63 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"tfsw/internal/utils"
+)
+
+const (
+	tokenEnv string = "TFSW_TOKEN"
+)
+
+// configureClient sets up the HTTP client used for downloads from the
+// configuration. A token in ${TFSW_TOKEN} is only sent to the hosts the
+// releases and release index are fetched from, anything else falls back
+// to the credentials in the .netrc file. Partial downloads are kept in the
+// cache directory, so they can be resumed by a later run
+func configureClient() error {
+	var hosts []string
+	for _, s := range []string{artifactURL("0.0.0", "file"), indexURL()} {
+		if u, err := url.Parse(s); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+
+	c, err := utils.NewClient(utils.ClientOptions{
+		CABundle:   config.CABundle,
+		ClientCert: config.ClientCert,
+		ClientKey:  config.ClientKey,
+		Netrc:      config.NetrcPath,
+		Token:      os.Getenv(tokenEnv),
+		TokenHosts: hosts,
+	})
+	if err != nil {
+		return err
+	}
+
+	utils.SetClient(c)
+	utils.PartialDirectory = filepath.Join(config.CacheDirectory, "downloads")
+	return nil
+}
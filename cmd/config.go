@@ -0,0 +1,259 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configCmd = &cobra.Command{
+		Long:  "Inspect and edit the config files. Settings are taken from the first of the command line flags, TFSW_* environment variables, the project file (" + projectConfigFileName + " in the current directory or a parent), the user file, and the defaults",
+		Short: "Manage configuration",
+		Use:   "config",
+	}
+	configGetCmd = &cobra.Command{
+		Args:              cobra.ExactArgs(1),
+		Long:              "Prints the value in effect for a setting, wherever it came from",
+		Run:               configGetRun,
+		Short:             "Print a setting",
+		Use:               "get KEY",
+		ValidArgsFunction: configValidArgs,
+	}
+	configListCmd = &cobra.Command{
+		Args:  cobra.NoArgs,
+		Long:  "Lists every setting with the value in effect and where it came from, followed by any product settings from the config files",
+		Run:   configListRun,
+		Short: "List settings",
+		Use:   "list",
+	}
+	configPathCmd = &cobra.Command{
+		Args:             cobra.NoArgs,
+		Long:             "Prints the path of the user config file, or with --project, the project config file",
+		PersistentPreRun: tolerateConfig,
+		Run:              configPathRun,
+		Short:            "Print the path of a config file",
+		Use:              "path",
+	}
+	configSetCmd = &cobra.Command{
+		Args:              cobra.ExactArgs(2),
+		Long:              "Sets a setting in the user config file, or with --project, the project config file. Product settings are set with keys of the form products.NAME.SETTING. As a project file may come from anywhere, it can only set product, cache_ttl, lock_timeout, and the title, tool_name, version_file, and release_notes_url of a product",
+		PersistentPreRun:  tolerateConfig,
+		Run:               configSetRun,
+		Short:             "Change a setting",
+		Use:               "set KEY VALUE",
+		ValidArgsFunction: configValidArgs,
+	}
+)
+
+func init() {
+	// Adds config as a child command of tfsw, with its own child commands
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configSetCmd)
+
+	// Add any extra command line flags for config here
+	configPathCmd.Flags().Bool("project", false, "Print the path of the project config file")
+	configSetCmd.Flags().Bool("project", false, "Write to the project config file, rather than the user config file")
+}
+
+// configGetRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `config get` command
+func configGetRun(cmd *cobra.Command, args []string) {
+	key := args[0]
+	if s, ok := lookupSetting(key); ok {
+		fmt.Println(s.value(config))
+		os.Exit(0)
+	}
+
+	if v, ok := config.File[key]; ok {
+		fmt.Println(v)
+		os.Exit(0)
+	}
+
+	if strings.HasPrefix(key, "products.") {
+		fmt.Fprintf(os.Stderr, "%s is not set\n", key)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s is not a setting\n", key)
+	}
+	os.Exit(1)
+}
+
+// configListRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `config list` command
+func configListRun(cmd *cobra.Command, args []string) {
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Key", "Value", "Source"})
+
+	for _, s := range settings {
+		tw.AppendRow(table.Row{s.key, s.value(config), configSource(cmd, s)})
+	}
+
+	for _, k := range config.fileKeys() {
+		tw.AppendRow(table.Row{k, config.File[k], configSource(cmd, setting{key: k})})
+	}
+
+	fmt.Println(tw.Render())
+	os.Exit(0)
+}
+
+// configPathRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `config path` command
+func configPathRun(cmd *cobra.Command, args []string) {
+	project, _ := cmd.Flags().GetBool("project")
+	if !project {
+		fmt.Println(config.UserFile)
+		os.Exit(0)
+	}
+
+	file, err := projectFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to find the project config file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(file)
+	os.Exit(0)
+}
+
+// configSetRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `config set` command
+func configSetRun(cmd *cobra.Command, args []string) {
+	key, value := args[0], args[1]
+
+	file := config.UserFile
+	project, _ := cmd.Flags().GetBool("project")
+	if project {
+		var err error
+		if file, err = projectFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to find the project config file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := validateConfigValue(key, value, project); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to set %s: %v\n", key, err)
+		os.Exit(1)
+	}
+
+	if err := writeConfigValue(file, key, value); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to set %s: %v\n", key, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s set to %q in %s\n", key, value, file)
+	os.Exit(0)
+}
+
+// configValidArgs provides shell completion of the setting names for get
+// and set
+func configValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var keys []string
+	for _, s := range settings {
+		keys = append(keys, s.key)
+	}
+
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+// configSource returns where the value in effect for a setting came from
+func configSource(cmd *cobra.Command, s setting) string {
+	if s.flag != "" && cmd.Flags().Changed(s.flag) {
+		return "flag"
+	}
+
+	if s.env != "" {
+		if _, ok := os.LookupEnv(s.env); ok {
+			return s.env
+		}
+	}
+
+	if _, ok := config.ProjectValues[s.key]; ok {
+		return "project"
+	}
+
+	if _, ok := config.UserValues[s.key]; ok {
+		return "user"
+	}
+
+	return "default"
+}
+
+// projectFile returns the project config file in use, or where one would
+// be created in the current directory if there isn't one
+func projectFile() (string, error) {
+	if config.ProjectFile != "" {
+		return config.ProjectFile, nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(wd, projectConfigFileName), nil
+}
+
+// validateConfigValue checks a value is valid for a key before it's
+// written to a config file, so a typo doesn't stop tfsw from starting
+func validateConfigValue(key, value string, project bool) error {
+	if project && userOnlyKey(key) {
+		return fmt.Errorf("it can only be set in %s", config.UserFile)
+	}
+
+	if strings.HasPrefix(key, "products.") {
+		rest := strings.TrimPrefix(key, "products.")
+		i := strings.LastIndex(rest, ".")
+		if i == -1 {
+			return fmt.Errorf("expected products.NAME.SETTING")
+		}
+
+		if !productNameRegex.MatchString(rest[:i]) {
+			return fmt.Errorf("%q is not a valid product name", rest[:i])
+		}
+
+		return (&product{}).set(map[string]string{rest[i+1:]: value})
+	}
+
+	s, ok := lookupSetting(key)
+	if !ok {
+		return fmt.Errorf("not a setting")
+	}
+
+	if key == "product" {
+		if _, err := lookupProduct(value); err != nil {
+			return err
+		}
+	}
+
+	return s.set(&configuration{HomeDirectory: config.HomeDirectory}, value)
+}
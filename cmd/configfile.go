@@ -0,0 +1,272 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	configFileName string = "config.toml"
+	configKeyExpr  string = `^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`
+)
+
+var (
+	configKeyRegex *regexp.Regexp = regexp.MustCompile(configKeyExpr)
+)
+
+// readConfigFile parses the subset of TOML used by tfsw's config file,
+// which is tables and keys with string, integer, or boolean values:
+//
+//	mirror = "https://artifactory.example.com/hashicorp"
+//
+//	[products.packer]
+//	binary = "packer"
+//
+// Keys within a table are returned prefixed with the table name e.g.
+// "products.packer.binary". It returns an empty map if the file doesn't
+// exist
+func readConfigFile(file string) (map[string]string, error) {
+	values := map[string]string{}
+
+	fh, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return values, nil
+		}
+		return nil, err
+	}
+	defer fh.Close()
+
+	var table string
+	scanner := bufio.NewScanner(fh)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end == -1 || !isConfigComment(line[end+1:]) {
+				return nil, fmt.Errorf("%s:%d: invalid table header", file, n)
+			}
+
+			table = strings.TrimSpace(line[1:end])
+			if !configKeyRegex.MatchString(table) {
+				return nil, fmt.Errorf("%s:%d: invalid table name %q", file, n, table)
+			}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq == -1 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", file, n)
+		}
+
+		key := strings.TrimSpace(line[:eq])
+		if !configKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("%s:%d: invalid key %q", file, n, key)
+		}
+
+		value, err := parseConfigValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, n, err)
+		}
+
+		if table != "" {
+			key = table + "." + key
+		}
+
+		values[key] = value
+	}
+
+	return values, scanner.Err()
+}
+
+// parseConfigValue parses a single value, which is either a basic or
+// literal string, or a bare integer or boolean, optionally followed by a
+// comment
+func parseConfigValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+
+			if s[i] == '"' {
+				if !isConfigComment(s[i+1:]) {
+					return "", fmt.Errorf("unexpected text after string")
+				}
+
+				v, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", fmt.Errorf("invalid string %s", s[:i+1])
+				}
+				return v, nil
+			}
+		}
+
+		return "", fmt.Errorf("unterminated string")
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated string")
+		}
+
+		if !isConfigComment(s[end+2:]) {
+			return "", fmt.Errorf("unexpected text after string")
+		}
+
+		return s[1 : end+1], nil
+	}
+
+	if i := strings.Index(s, "#"); i != -1 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	if s == "true" || s == "false" {
+		return s, nil
+	}
+
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return s, nil
+	}
+
+	return "", fmt.Errorf("invalid value %q, strings must be quoted", s)
+}
+
+// isConfigComment reports whether the rest of a line is empty, or only a
+// comment
+func isConfigComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || strings.HasPrefix(s, "#")
+}
+
+// writeConfigValue sets a key in a config file, creating the file if it
+// doesn't exist. The rest of the file, including comments, is left as it
+// is. A key within a table e.g. "products.packer.binary" is written to
+// that table, which is added to the end of the file if it's not there
+func writeConfigValue(file, key, value string) error {
+	if !configKeyRegex.MatchString(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+
+	table, name := "", key
+	if i := strings.LastIndex(key, "."); i != -1 && strings.HasPrefix(key, "products.") {
+		table, name = key[:i], key[i+1:]
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var lines []string
+	if len(b) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	}
+
+	entry := name + " = " + strconv.Quote(value)
+
+	// NOTE: A new key goes after the last key already in its table, or
+	// straight after the table header if it's empty
+	current, found, insert := "", table == "", 0
+	for i, l := range lines {
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, "[") {
+			if end := strings.Index(l, "]"); end != -1 {
+				current = strings.TrimSpace(l[1:end])
+				if current == table {
+					found, insert = true, i+1
+				}
+			}
+			continue
+		}
+
+		if current != table || l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		if eq := strings.Index(l, "="); eq != -1 && strings.TrimSpace(l[:eq]) == name {
+			lines[i] = entry
+			return writeConfigLines(file, lines)
+		}
+		insert = i + 1
+	}
+
+	if !found {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+table+"]", entry)
+		return writeConfigLines(file, lines)
+	}
+
+	add := []string{entry}
+	if table == "" && insert == 0 && len(lines) > 0 {
+		// NOTE: Keep the top level keys apart from the first table
+		add = append(add, "")
+	}
+	lines = append(lines[:insert], append(add, lines[insert:]...)...)
+
+	return writeConfigLines(file, lines)
+}
+
+// writeConfigLines replaces a config file with the given lines. The new
+// file is written alongside the old one then renamed over it, so the file
+// is never left half written
+func writeConfigLines(file string, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
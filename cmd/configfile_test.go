@@ -0,0 +1,221 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    map[string]string
+		invalid string
+	}{
+		{
+			name: "keys and tables",
+			src: `# tfsw config
+mirror = "https://artifactory.example.com/hashicorp"
+offline = true
+jobs = 4
+
+[products.packer]
+binary = 'packer' # a literal string
+title = "Packer \"HCP\""
+`,
+			want: map[string]string{
+				"mirror":                 "https://artifactory.example.com/hashicorp",
+				"offline":                "true",
+				"jobs":                   "4",
+				"products.packer.binary": "packer",
+				"products.packer.title":  `Packer "HCP"`,
+			},
+		},
+		{
+			name: "comments after values",
+			src:  "mirror = \"https://example.com/#anchor\" # comment\njobs = 4 # comment\n[products.vault] # comment\nbinary = \"vault\"\n",
+			want: map[string]string{
+				"mirror":                "https://example.com/#anchor",
+				"jobs":                  "4",
+				"products.vault.binary": "vault",
+			},
+		},
+		{name: "empty", src: "\n# nothing here\n", want: map[string]string{}},
+		{name: "unquoted string", src: "mirror = https://example.com\n", invalid: "1: invalid value"},
+		{name: "unterminated string", src: "\nmirror = \"https://example.com\n", invalid: "2: unterminated string"},
+		{name: "text after string", src: "mirror = \"a\" \"b\"\n", invalid: "1: unexpected text after string"},
+		{name: "no value", src: "mirror\n", invalid: "1: expected key = value"},
+		{name: "invalid key", src: "mirror url = \"a\"\n", invalid: "1: invalid key"},
+		{name: "invalid table header", src: "[products.packer\n", invalid: "1: invalid table header"},
+		{name: "invalid table name", src: "[products..packer]\n", invalid: "1: invalid table name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), configFileName)
+			if err := os.WriteFile(file, []byte(tt.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readConfigFile(file)
+			if tt.invalid != "" {
+				if err == nil || !strings.Contains(err.Error(), configFileName+":"+tt.invalid) {
+					t.Fatalf("readConfigFile() error = %v, want %q", err, tt.invalid)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readConfigFile() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readConfigFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadConfigFileMissing(t *testing.T) {
+	got, err := readConfigFile(filepath.Join(t.TempDir(), configFileName))
+	if err != nil {
+		t.Fatalf("readConfigFile() error = %v", err)
+	}
+
+	if len(got) != 0 {
+		t.Errorf("readConfigFile() = %v, want an empty map", got)
+	}
+}
+
+func TestWriteConfigValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		key   string
+		value string
+		want  string
+	}{
+		{
+			name:  "new file",
+			key:   "mirror",
+			value: "https://example.com",
+			want:  "mirror = \"https://example.com\"\n",
+		},
+		{
+			name:  "replace a key, keeping comments",
+			src:   "# tfsw config\nmirror = \"https://old.example.com\" # old\nverify = \"checksum\"\n",
+			key:   "mirror",
+			value: "https://example.com",
+			want:  "# tfsw config\nmirror = \"https://example.com\"\nverify = \"checksum\"\n",
+		},
+		{
+			name:  "add a key after the other top level keys",
+			src:   "verify = \"checksum\"\n\n[products.packer]\nbinary = \"packer\"\n",
+			key:   "mirror",
+			value: "https://example.com",
+			want:  "verify = \"checksum\"\nmirror = \"https://example.com\"\n\n[products.packer]\nbinary = \"packer\"\n",
+		},
+		{
+			name:  "add a top level key before the first table",
+			src:   "[products.packer]\nbinary = \"packer\"\n",
+			key:   "mirror",
+			value: "https://example.com",
+			want:  "mirror = \"https://example.com\"\n\n[products.packer]\nbinary = \"packer\"\n",
+		},
+		{
+			name:  "add a key to an existing table",
+			src:   "[products.packer]\nbinary = \"packer\"\n\n[products.vault]\nbinary = \"vault\"\n",
+			key:   "products.packer.title",
+			value: "Packer",
+			want:  "[products.packer]\nbinary = \"packer\"\ntitle = \"Packer\"\n\n[products.vault]\nbinary = \"vault\"\n",
+		},
+		{
+			name:  "replace a key in a table, not the top level",
+			src:   "binary = \"top\"\n\n[products.packer]\nbinary = \"packer\"\n",
+			key:   "products.packer.binary",
+			value: "packer2",
+			want:  "binary = \"top\"\n\n[products.packer]\nbinary = \"packer2\"\n",
+		},
+		{
+			name:  "add a table",
+			src:   "mirror = \"https://example.com\"\n",
+			key:   "products.packer.binary",
+			value: "packer",
+			want:  "mirror = \"https://example.com\"\n\n[products.packer]\nbinary = \"packer\"\n",
+		},
+		{
+			name:  "quote values",
+			key:   "products.packer.title",
+			value: `Packer "HCP"`,
+			want:  "[products.packer]\ntitle = \"Packer \\\"HCP\\\"\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), configFileName)
+			if tt.src != "" {
+				if err := os.WriteFile(file, []byte(tt.src), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := writeConfigValue(file, tt.key, tt.value); err != nil {
+				t.Fatalf("writeConfigValue() error = %v", err)
+			}
+
+			b, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.want {
+				t.Errorf("writeConfigValue() wrote\n%s\nwant\n%s", b, tt.want)
+			}
+
+			// NOTE: Whatever is written must read back as the same value
+			values, err := readConfigFile(file)
+			if err != nil {
+				t.Fatalf("readConfigFile() error = %v", err)
+			}
+
+			if values[tt.key] != tt.value {
+				t.Errorf("read back %q = %q, want %q", tt.key, values[tt.key], tt.value)
+			}
+		})
+	}
+}
+
+func TestWriteConfigValueInvalidKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), configFileName)
+	if err := writeConfigValue(file, "mirror url", "a"); err == nil {
+		t.Fatal("writeConfigValue() error = nil, want an error")
+	}
+
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("writeConfigValue() created %s for an invalid key", file)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	constraintExpr string = `^\s*(=|!=|>=|<=|>|<|~>)?\s*v?([0-9]+)(?:\.([0-9]+))?(?:\.([0-9]+))?(?:-(alpha|beta|oci|rc)([0-9]*))?\s*$`
+)
+
+var (
+	constraintRegex *regexp.Regexp = regexp.MustCompile(constraintExpr)
+)
+
+// constraint is a single version constraint using the same syntax as
+// Terraform's required_version e.g. ">= 1.3" or "~> 1.5.0"
+type constraint struct {
+	op  string
+	ver *Version
+
+	// segments is the number of version segments that were given, as
+	// the pessimistic operator behaves differently for "~> 1.5" and
+	// "~> 1.5.0"
+	segments int
+}
+
+// constraints is a set of constraints that must all be satisfied
+type constraints []constraint
+
+// parseConstraints parses a comma separated list of version constraints
+// e.g. ">= 1.3, < 1.6"
+func parseConstraints(s string) (constraints, error) {
+	var cs constraints
+	for _, part := range strings.Split(s, ",") {
+		m := constraintRegex.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("%w: %q is not a valid constraint", ErrVersionInvalid, strings.TrimSpace(part))
+		}
+
+		c := constraint{op: m[1], ver: &Version{Label: m[5], Number: m[6]}, segments: 1}
+		if c.op == "" {
+			c.op = "="
+		}
+
+		// NOTE: The regex guarantees the segments are all digits
+		c.ver.Major, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			c.ver.Minor, _ = strconv.Atoi(m[3])
+			c.segments++
+		}
+
+		if m[4] != "" {
+			c.ver.Patch, _ = strconv.Atoi(m[4])
+			c.segments++
+		}
+
+		cs = append(cs, c)
+	}
+
+	return cs, nil
+}
+
+// check reports whether the version satisfies every constraint
+func (cs constraints) check(v *Version) bool {
+	for _, c := range cs {
+		if !c.check(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the constraints in their canonical form
+func (cs constraints) String() string {
+	s := make([]string, 0, len(cs))
+	for _, c := range cs {
+		s = append(s, c.String())
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// check reports whether the version satisfies the constraint. Like
+// Terraform, a pre-release only satisfies a constraint if the constraint
+// refers to a pre-release of the same version, so ">= 1.5.0" will never
+// select 1.6.0-beta2
+func (c constraint) check(v *Version) bool {
+	switch c.op {
+	case "=":
+		return v.Equal(c.ver)
+	case "!=":
+		return !v.Equal(c.ver)
+	}
+
+	if !c.prereleaseCheck(v) {
+		return false
+	}
+
+	switch c.op {
+	case ">":
+		return v.Compare(c.ver) > 0
+	case ">=":
+		return v.Compare(c.ver) >= 0
+	case "<":
+		return v.Compare(c.ver) < 0
+	case "<=":
+		return v.Compare(c.ver) <= 0
+	case "~>":
+		return c.pessimistic(v)
+	}
+
+	return false
+}
+
+// prereleaseCheck reports whether a pre-release is allowed to be
+// compared against the constraint
+func (c constraint) prereleaseCheck(v *Version) bool {
+	switch {
+	case v.Prerelease() && c.ver.Prerelease():
+		return v.Major == c.ver.Major && v.Minor == c.ver.Minor && v.Patch == c.ver.Patch
+	case v.Prerelease():
+		return false
+	}
+
+	return true
+}
+
+// pessimistic implements the ~> operator, which allows only the right
+// most given segment to increase e.g. "~> 1.5" allows 1.6.0 but not
+// 2.0.0, whereas "~> 1.5.0" allows 1.5.7 but not 1.6.0
+func (c constraint) pessimistic(v *Version) bool {
+	if c.ver.Prerelease() && !v.Prerelease() {
+		return false
+	}
+
+	if v.LessThan(c.ver) {
+		return false
+	}
+
+	vs := []int{v.Major, v.Minor, v.Patch}
+	cs := []int{c.ver.Major, c.ver.Minor, c.ver.Patch}
+	for i := 0; i < c.segments-1; i++ {
+		if vs[i] != cs[i] {
+			return false
+		}
+	}
+
+	return vs[c.segments-1] >= cs[c.segments-1]
+}
+
+// String returns the constraint in its canonical form
+func (c constraint) String() string {
+	segs := []string{strconv.Itoa(c.ver.Major), strconv.Itoa(c.ver.Minor), strconv.Itoa(c.ver.Patch)}
+
+	s := c.op + " " + strings.Join(segs[:c.segments], ".")
+	if c.ver.Prerelease() {
+		s += "-" + c.ver.Label + c.ver.Number
+	}
+
+	return s
+}
@@ -0,0 +1,123 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseConstraints(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		invalid bool
+	}{
+		{in: "1.5.7", want: "= 1.5.7"},
+		{in: "v1.5.7", want: "= 1.5.7"},
+		{in: ">= 1.3", want: ">= 1.3"},
+		{in: "~>1.5.0", want: "~> 1.5.0"},
+		{in: ">= 1.3, < 1.6", want: ">= 1.3, < 1.6"},
+		{in: " != 1.4.0 ", want: "!= 1.4.0"},
+		{in: ">= 1.6.0-beta2", want: ">= 1.6.0-beta2"},
+		{in: "", invalid: true},
+		{in: ">= 1.3,", invalid: true},
+		{in: "=> 1.3", invalid: true},
+		{in: "1.5.7.1", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			cs, err := parseConstraints(tt.in)
+			if tt.invalid {
+				if !errors.Is(err, ErrVersionInvalid) {
+					t.Fatalf("parseConstraints(%q) error = %v, want ErrVersionInvalid", tt.in, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseConstraints(%q) error = %v", tt.in, err)
+			}
+
+			if cs.String() != tt.want {
+				t.Errorf("parseConstraints(%q) = %q, want %q", tt.in, cs.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConstraintsCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{constraint: "1.5.7", version: "1.5.7", want: true},
+		{constraint: "1.5.7", version: "1.5.6", want: false},
+		{constraint: "!= 1.5.7", version: "1.5.6", want: true},
+		{constraint: ">= 1.3", version: "1.3.0", want: true},
+		{constraint: ">= 1.3", version: "1.2.9", want: false},
+		{constraint: "> 1.3.0", version: "1.3.0", want: false},
+		{constraint: "< 1.6", version: "1.5.7", want: true},
+		{constraint: "<= 1.5.7", version: "1.5.7", want: true},
+		{constraint: ">= 1.3, < 1.6", version: "1.6.0", want: false},
+
+		// NOTE: "~> 1.5" allows the minor version to increase, whereas
+		// "~> 1.5.0" only allows the patch version to
+		{constraint: "~> 1.5", version: "1.5.0", want: true},
+		{constraint: "~> 1.5", version: "1.9.2", want: true},
+		{constraint: "~> 1.5", version: "2.0.0", want: false},
+		{constraint: "~> 1.5", version: "1.4.9", want: false},
+		{constraint: "~> 1.5.0", version: "1.5.7", want: true},
+		{constraint: "~> 1.5.0", version: "1.6.0", want: false},
+		{constraint: "~> 1.5.3", version: "1.5.2", want: false},
+		{constraint: "~> 1", version: "1.9.0", want: true},
+		{constraint: "~> 1", version: "2.0.0", want: true},
+
+		// NOTE: A pre-release only matches a constraint naming a
+		// pre-release of the same version
+		{constraint: ">= 1.5.0", version: "1.6.0-beta2", want: false},
+		{constraint: "~> 1.5", version: "1.6.0-rc1", want: false},
+		{constraint: ">= 1.6.0-beta1", version: "1.6.0-beta2", want: true},
+		{constraint: ">= 1.6.0-beta1", version: "1.7.0-beta1", want: false},
+		{constraint: ">= 1.6.0-beta1", version: "1.6.0", want: true},
+		{constraint: "1.6.0-rc1", version: "1.6.0-rc1", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			cs, err := parseConstraints(tt.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v, err := parseVersion(tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := cs.check(v); got != tt.want {
+				t.Errorf("%q check %s = %t, want %t", tt.constraint, tt.version, got, tt.want)
+			}
+		})
+	}
+}
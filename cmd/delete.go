@@ -23,10 +23,9 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
 
 	"github.com/spf13/cobra"
-	"tfsw/internal/utils"
 )
 
 var (
@@ -52,39 +51,63 @@ func init() {
 // deleteRun is passed directly to the Cobra Run argument and executes
 // the primary logic for the `delete` command
 func deleteRun(cmd *cobra.Command, args []string) {
+	var vers versions
+
 	clean, _ := cmd.Flags().GetBool("clean")
 	if clean {
-		args = deleteCleanArgs(config.InstalledVersions, config.CurrentVersion)
+		vers = deleteCleanArgs(config.InstalledVersions, config.CurrentVersion)
 	}
 
 	for _, arg := range args {
-		err := deleteVersion(arg, config.CurrentVersion)
+		// NOTE: Arguments have already been checked by validateVersion
+		v, _ := parseVersion(arg)
+		vers = append(vers, v)
+	}
+
+	unlock, err := lockStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to lock the installed versions: %v\n", err)
+		os.Exit(1)
+	}
+
+	// NOTE: Another process may have switched versions while waiting for
+	// the lock
+	if err := config.currentVersion(); err != nil {
+		unlock()
+		fmt.Fprintf(os.Stderr, "Unable to find the active version: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, ver := range vers {
+		err := deleteVersion(ver, config.CurrentVersion)
 		switch err {
 		case ErrVersionSame:
-			fmt.Printf("Terraform %s is active, please switch to another version before removing\n", arg)
+			fmt.Printf("%s %s is active, please switch to another version before removing\n", config.Product.Title, ver)
 		case ErrVersionNotExist:
-			fmt.Printf("Terraform %s has already been removed\n", arg)
+			fmt.Printf("%s %s has already been removed\n", config.Product.Title, ver)
 		case nil:
-			fmt.Printf("Terraform %s has been removed\n", arg)
+			fmt.Printf("%s %s has been removed\n", config.Product.Title, ver)
 		default:
+			unlock()
 			fmt.Fprintf(os.Stderr, "Encountered an unhandled error: %v\n", err)
 			os.Exit(1)
 		}
 	}
 
+	unlock()
 	os.Exit(0)
 }
 
 // deleteCleanArgs returns a slice of the installed versions, minus the current
 // version, to be deleted
-func deleteCleanArgs(inst []string, cur string) []string {
-	idx := utils.Index(inst, cur)
-	return utils.Reslice(inst, idx)
+func deleteCleanArgs(inst versions, cur *Version) versions {
+	return inst.without(cur)
 }
 
 // deleteValidArgs dynamically generates completion arguments for the remove
 // command. It gathers a list of the currently installed versions, then
-// modifies that list based on the current command line input:
+// modifies that list based on the current command line input. Versions are
+// offered newest first:
 //
 //	$ tfsw delete [tab][tab]
 //	1.0.0 0.15.5 0.14.7
@@ -92,24 +115,27 @@ func deleteCleanArgs(inst []string, cur string) []string {
 //	$ tfsw delete 0.14.7 [tab][tab]
 //	1.0.0 0.15.5
 func deleteValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	var validArgs []string
-
+	var given versions
 	for i := range args {
-		index := utils.Index(versionsInstalled, args[i])
-		validArgs = utils.Reslice(versionsInstalled, index)
+		if v, err := parseVersion(args[i]); err == nil {
+			given = append(given, v)
+		}
 	}
 
-	return validArgs, cobra.ShellCompDirectiveNoFileComp
+	validArgs := config.InstalledVersions.without(given...)
+	sort.Sort(sort.Reverse(validArgs))
+
+	return validArgs.strings(), cobra.ShellCompDirectiveNoFileComp
 }
 
 // deleteVersion takes a Terraform version unumber, and the current active
 // version, and deletes the version if they don't match.
-func deleteVersion(ver, cur string) error {
-	if ver == cur {
+func deleteVersion(ver, cur *Version) error {
+	if ver.Equal(cur) {
 		return ErrVersionSame
 	}
 
-	dst := filepath.Join(config.ConfigDirectory, ver)
+	dst := config.Product.versionDir(ver)
 
 	if _, err := os.Stat(dst); err != nil {
 		return ErrVersionNotExist
@@ -0,0 +1,547 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+	"tfsw/internal/utils"
+)
+
+const (
+	checkPass checkStatus = iota
+	checkWarn
+	checkFail
+
+	probeTimeout time.Duration = 10 * time.Second
+)
+
+var (
+	doctorCmd = &cobra.Command{
+		Args:             cobra.NoArgs,
+		Long:             "Checks the environment tfsw runs in for problems, such as an invalid configuration, the binary directory missing from $PATH, another terraform shadowing the managed one, dangling symlinks, damaged installs, and an unreachable mirror. Each check passes, warns, or fails with a hint on how to fix it. The exit code is 1 if any check fails, or with --strict, if any warns",
+		PersistentPreRun: doctorPreRun,
+		Run:              doctorRun,
+		Short:            "Check the environment for problems",
+		Use:              "doctor",
+	}
+
+	// doctorConfigErrors are the problems found with the configuration,
+	// which doctor reports rather than stopping at
+	doctorConfigErrors []error
+)
+
+// checkStatus is the outcome of a doctor check
+type checkStatus int
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkPass:
+		return "PASS"
+	case checkWarn:
+		return "WARN"
+	}
+
+	return "FAIL"
+}
+
+// checkResult is the outcome of a doctor check, with a hint on how to fix
+// anything that didn't pass
+type checkResult struct {
+	Hint    string
+	Message string
+	Name    string
+	Status  checkStatus
+}
+
+func init() {
+	// Add doctor as a child command of tfsw
+	rootCmd.AddCommand(doctorCmd)
+
+	// Add any extra command line flags for doctor here
+	doctorCmd.Flags().Bool("strict", false, "Exit with an error if any check warns, as well as if any fails")
+}
+
+// doctorPreRun is passed directly to the Cobra PersistentPreRun argument
+// in place of validateConfig, so a broken configuration is reported as a
+// failed check. Nothing is migrated until it's been diagnosed
+func doctorPreRun(cmd *cobra.Command, args []string) {
+	doctorConfigErrors = checkConfig(false)
+}
+
+// doctorRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `doctor` command
+func doctorRun(cmd *cobra.Command, args []string) {
+	strict, _ := cmd.Flags().GetBool("strict")
+
+	var results []checkResult
+	results = append(results, checkConfiguration()...)
+	results = append(results, checkDirectories()...)
+	results = append(results, checkPath()...)
+	for _, p := range doctorProducts() {
+		results = append(results, checkProduct(p)...)
+	}
+	results = append(results, checkStaging()...)
+	results = append(results, checkNetwork()...)
+
+	counts := map[checkStatus]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		fmt.Printf("[%s] %s: %s\n", r.Status, r.Name, r.Message)
+		if r.Status != checkPass && r.Hint != "" {
+			fmt.Printf("       %s\n", r.Hint)
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d warned, %d failed\n", counts[checkPass], counts[checkWarn], counts[checkFail])
+
+	if counts[checkFail] > 0 || (strict && counts[checkWarn] > 0) {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// doctorProducts returns the products worth checking, which are the one
+// being managed and any others with a symlink or installed versions
+func doctorProducts() []*product {
+	var ps []*product
+	for _, n := range productNames() {
+		p := products[n]
+		if p == config.Product {
+			ps = append(ps, p)
+			continue
+		}
+
+		if _, err := os.Lstat(p.symlink()); err == nil {
+			ps = append(ps, p)
+			continue
+		}
+
+		if inst, err := installedVersions(p); err == nil && len(inst) > 0 {
+			ps = append(ps, p)
+		}
+	}
+
+	return ps
+}
+
+// checkConfiguration reports the problems found loading the configuration
+// and applying the command line flags
+func checkConfiguration() []checkResult {
+	if len(doctorConfigErrors) == 0 {
+		return []checkResult{{Name: "configuration", Message: "the config files, environment, and flags are valid"}}
+	}
+
+	var results []checkResult
+	for _, err := range doctorConfigErrors {
+		results = append(results, checkResult{
+			Hint:    "Fix the value in the config file, environment variable, or flag it came from, or change it with: " + basename + " config set",
+			Message: err.Error(),
+			Name:    "configuration",
+			Status:  checkFail,
+		})
+	}
+
+	return results
+}
+
+// checkDirectories checks the directories tfsw uses exist, or can be
+// created, and are writable
+func checkDirectories() []checkResult {
+	dirs := []struct {
+		dir      string
+		key      string
+		name     string
+		required bool
+	}{
+		{config.BinaryDirectory, "bin_dir", "bin directory", true},
+		{config.StoreDirectory, "store_dir", "store directory", false},
+		{config.CacheDirectory, "cache_dir", "cache directory", false},
+		{config.ConfigDirectory, "", "config directory", false},
+	}
+
+	var results []checkResult
+	for _, d := range dirs {
+		r := checkResult{Name: d.name}
+
+		info, err := os.Stat(d.dir)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && d.required:
+			r.Status = checkFail
+			r.Message = d.dir + " does not exist, so symlinks can't be created in it"
+			r.Hint = "Create it with: mkdir -p " + d.dir
+		case errors.Is(err, os.ErrNotExist):
+			r.Message = d.dir + " does not exist yet, it will be created when it's needed"
+		case err != nil:
+			r.Status = checkFail
+			r.Message = err.Error()
+		case !info.IsDir():
+			r.Status = checkFail
+			r.Message = d.dir + " is not a directory"
+			r.Hint = "Move it out of the way"
+			if d.key != "" {
+				r.Hint += ", or set " + d.key + " to somewhere else"
+			}
+		default:
+			r.Status, r.Message, r.Hint = checkWritable(d.dir, info)
+		}
+
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// checkWritable checks a directory can be written to, and that nobody
+// else can replace what's in it
+func checkWritable(dir string, info os.FileInfo) (checkStatus, string, string) {
+	f, err := os.CreateTemp(dir, ".tfsw-doctor-*")
+	if err != nil {
+		return checkFail, dir + " is not writable: " + err.Error(), "Fix its permissions with: chmod u+w " + dir
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	// NOTE: Windows has no equivalent of the Unix permission bits
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0002 != 0 {
+		return checkWarn, dir + " is writable by everyone, so anyone could replace the binaries in it", "Fix its permissions with: chmod o-w " + dir
+	}
+
+	return checkPass, dir + " exists and is writable", ""
+}
+
+// checkPath checks the bin directory is on $PATH
+func checkPath() []checkResult {
+	r := checkResult{Name: "PATH"}
+	if onPath(config.BinaryDirectory) {
+		r.Message = config.BinaryDirectory + " is on $PATH"
+		return []checkResult{r}
+	}
+
+	r.Status = checkFail
+	r.Message = config.BinaryDirectory + " is not on $PATH, so the symlinks in it won't be found"
+	r.Hint = fmt.Sprintf("Add it to $PATH in your shell profile e.g. export PATH=%q", config.BinaryDirectory+string(os.PathListSeparator)+"$PATH")
+	return []checkResult{r}
+}
+
+// onPath reports whether dir is one of the directories on $PATH
+func onPath(dir string) bool {
+	want, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		want = filepath.Clean(dir)
+	}
+
+	for _, p := range filepath.SplitList(os.Getenv("PATH")) {
+		got, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			got = filepath.Clean(p)
+		}
+
+		if got == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkProduct checks a product's symlink, that the shell resolves its
+// binary to the symlink, and that its installed versions are intact
+func checkProduct(p *product) []checkResult {
+	inst, err := installedVersions(p)
+	if err != nil {
+		return []checkResult{{Name: p.Name, Status: checkFail, Message: "unable to read installed versions: " + err.Error()}}
+	}
+
+	results := []checkResult{checkSymlink(p, inst)}
+	if r, ok := checkResolved(p); ok {
+		results = append(results, r)
+	}
+
+	return append(results, checkStore(p)...)
+}
+
+// checkSymlink checks a product's symlink points at an installed version,
+// or at tfsw in shim mode
+func checkSymlink(p *product, inst versions) checkResult {
+	r := checkResult{Name: p.Name + " symlink"}
+	selectHint := fmt.Sprintf("Select an installed version with: %s --product %s select VERSION", basename, p.Name)
+
+	info, err := os.Lstat(p.symlink())
+	switch {
+	case errors.Is(err, os.ErrNotExist) && len(inst) > 0:
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("no %s version has been selected", p.Title)
+		r.Hint = selectHint
+		return r
+	case errors.Is(err, os.ErrNotExist):
+		r.Message = fmt.Sprintf("no %s versions are installed", p.Title)
+		return r
+	case err != nil:
+		r.Status = checkFail
+		r.Message = err.Error()
+		return r
+	case info.Mode()&os.ModeSymlink == 0:
+		r.Status = checkFail
+		r.Message = p.symlink() + " is not a symlink, so tfsw can't manage it"
+		r.Hint = "Move it out of the way, then select a version"
+		return r
+	}
+
+	link, _ := os.Readlink(p.symlink())
+	dst, err := filepath.EvalSymlinks(p.symlink())
+	if err != nil {
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("%s is dangling, %s doesn't exist", p.symlink(), link)
+		r.Hint = selectHint
+		return r
+	}
+
+	if shim, _ := shimLinked(p); shim {
+		r.Message = p.symlink() + " is a shim, versions are picked per directory"
+		return r
+	}
+
+	for _, v := range inst {
+		if bin, err := filepath.EvalSymlinks(p.versionBinary(v)); err == nil && bin == dst {
+			r.Message = fmt.Sprintf("%s points at %s %s", p.symlink(), p.Title, v)
+			return r
+		}
+	}
+
+	r.Status = checkWarn
+	r.Message = fmt.Sprintf("%s points at %s, which isn't managed by tfsw", p.symlink(), link)
+	r.Hint = selectHint
+	return r
+}
+
+// checkResolved checks the shell resolves a product's binary to its
+// symlink, rather than another copy earlier on $PATH. It reports false
+// if there's nothing to check
+func checkResolved(p *product) (checkResult, bool) {
+	r := checkResult{Name: p.Name + " on PATH"}
+
+	// NOTE: A dangling symlink is reported by checkSymlink, and can't be
+	// resolved anyway
+	_, lerr := os.Lstat(p.symlink())
+	if _, err := os.Stat(p.symlink()); lerr == nil && err != nil {
+		return r, false
+	}
+
+	found, err := exec.LookPath(p.binary())
+	switch {
+	case err != nil && lerr != nil:
+		return r, false
+	case err != nil:
+		r.Status = checkWarn
+		r.Message = p.binary() + " isn't found on $PATH"
+		r.Hint = "Add " + config.BinaryDirectory + " to $PATH"
+		return r, true
+	}
+
+	found, _ = filepath.Abs(found)
+	if lerr != nil {
+		r.Status = checkWarn
+		r.Message = found + " is on $PATH, but isn't managed by tfsw"
+		r.Hint = fmt.Sprintf("Remove it, then install a version with: %s --product %s select VERSION", basename, p.Name)
+		return r, true
+	}
+
+	a, aerr := os.Lstat(found)
+	b, berr := os.Lstat(p.symlink())
+	if aerr == nil && berr == nil && os.SameFile(a, b) {
+		r.Message = p.binary() + " resolves to " + p.symlink()
+		return r, true
+	}
+
+	r.Status = checkFail
+	r.Message = p.binary() + " resolves to " + found + ", which shadows " + p.symlink()
+	r.Hint = "Remove " + found + ", or move " + config.BinaryDirectory + " before " + filepath.Dir(found) + " on $PATH"
+	return r, true
+}
+
+// checkStore checks each version of a product in the store has a usable
+// binary. Directories left by a failed install aren't counted as installed
+// so are reported separately
+func checkStore(p *product) []checkResult {
+	entries, err := os.ReadDir(p.storeDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return []checkResult{{Name: p.Name + " store", Status: checkFail, Message: err.Error()}}
+	}
+
+	var results []checkResult
+	var intact int
+	for _, e := range entries {
+		ver, err := parseVersion(e.Name())
+		if !e.IsDir() || err != nil {
+			continue
+		}
+
+		r := checkResult{Name: fmt.Sprintf("%s %s", p.Name, ver), Status: checkFail}
+		reinstall := fmt.Sprintf("Reinstall it with: %s --product %s delete %s && %s --product %s new %s", basename, p.Name, ver, basename, p.Name, ver)
+
+		info, err := os.Stat(p.versionBinary(ver))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			r.Status = checkWarn
+			r.Message = p.versionDir(ver) + " has no " + p.binary() + ", it's left over from a failed install"
+			r.Hint = "Remove it with: rm -rf " + p.versionDir(ver)
+		case err != nil:
+			r.Message = err.Error()
+		case !info.Mode().IsRegular():
+			r.Message = p.versionBinary(ver) + " is not a regular file"
+			r.Hint = reinstall
+		case info.Size() == 0:
+			r.Message = p.versionBinary(ver) + " is empty"
+			r.Hint = reinstall
+		case runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0:
+			r.Message = p.versionBinary(ver) + " is not executable"
+			r.Hint = reinstall
+		default:
+			intact++
+			continue
+		}
+
+		results = append(results, r)
+	}
+
+	if intact > 0 {
+		results = append(results, checkResult{Name: p.Name + " store", Message: fmt.Sprintf("%d installed versions are intact", intact)})
+	}
+
+	return results
+}
+
+// checkStaging checks for installs that were interrupted, and versions
+// that haven't been moved out of the config directory yet
+func checkStaging() []checkResult {
+	var results []checkResult
+
+	if entries, err := os.ReadDir(stagingRoot()); err == nil && len(entries) > 0 {
+		results = append(results, checkResult{
+			Hint:    "They're removed by a later install once they're an hour old, or remove them with: rm -rf " + stagingRoot(),
+			Message: fmt.Sprintf("%d interrupted installs are in %s", len(entries), stagingRoot()),
+			Name:    "staging",
+			Status:  checkWarn,
+		})
+	}
+
+	for _, n := range productNames() {
+		p := products[n]
+		if paths, err := legacyEntries(p); err == nil && len(paths) > 0 {
+			results = append(results, checkResult{
+				Hint:    "They're moved to " + p.storeDir() + " by any other command, which shows an error if they can't be",
+				Message: fmt.Sprintf("%d %s entries are still in %s", len(paths), p.Title, filepath.Dir(paths[0])),
+				Name:    p.Name + " migration",
+				Status:  checkWarn,
+			})
+		}
+	}
+
+	return results
+}
+
+// checkNetwork checks the proxy settings, and that the release index can
+// be reached
+func checkNetwork() []checkResult {
+	uri := indexURL()
+	if dir, ok := localRepository(); ok {
+		r := checkResult{Name: "mirror", Message: dir + " is a local repository"}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			r.Status = checkWarn
+			r.Message = dir + " can't be read, so nothing can be installed from it"
+			r.Hint = "Mount or create the directory, or change the mirror"
+		}
+		return []checkResult{r}
+	}
+
+	results := []checkResult{checkProxy(uri)}
+
+	r := checkResult{Name: "mirror"}
+	if config.Offline {
+		r.Status = checkWarn
+		r.Message = "skipped as --offline is set"
+		return append(results, r)
+	}
+
+	status, err := utils.Probe(uri, probeTimeout)
+	switch {
+	case err != nil:
+		r.Status = checkFail
+		r.Message = "unable to reach " + uri + ": " + err.Error()
+		r.Hint = "Check the network, proxy, and mirror settings, or use --offline"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusProxyAuthRequired:
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("%s requires authentication, got HTTP %d", uri, status)
+		r.Hint = "Add credentials to " + config.NetrcPath + " or set " + tokenEnv
+	case status == http.StatusNotFound:
+		r.Status = checkFail
+		r.Message = uri + " was not found"
+		r.Hint = "Check the mirror is laid out like " + defaultRepositoryURL + ", or set index_url"
+	case status >= 500:
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("%s returned HTTP %d", uri, status)
+		r.Hint = "The server may be having problems, try again later"
+	default:
+		// NOTE: Some servers don't allow HEAD, but answering at all is
+		// enough to show they can be reached
+		r.Message = fmt.Sprintf("%s is reachable", uri)
+	}
+
+	return append(results, r)
+}
+
+// checkProxy reports the proxy, if any, used to reach uri
+func checkProxy(uri string) checkResult {
+	r := checkResult{Name: "proxy"}
+
+	req, err := http.NewRequest(http.MethodHead, uri, nil)
+	if err != nil {
+		r.Status = checkFail
+		r.Message = err.Error()
+		return r
+	}
+
+	proxy, err := http.ProxyFromEnvironment(req)
+	switch {
+	case err != nil:
+		r.Status = checkFail
+		r.Message = "the proxy settings are invalid: " + err.Error()
+		r.Hint = "Check $HTTPS_PROXY, $HTTP_PROXY, and $NO_PROXY"
+	case proxy == nil:
+		r.Message = "connecting to " + req.URL.Host + " directly"
+	default:
+		r.Message = "connecting to " + req.URL.Host + " through " + proxy.Redacted()
+	}
+
+	return r
+}
@@ -0,0 +1,91 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	execCmd = &cobra.Command{
+		Aliases:           []string{"run"},
+		Args:              cobra.MinimumNArgs(1),
+		Long:              "Runs a specific version of the selected product once, installing it if missing, without changing the active version",
+		Run:               execRun,
+		Short:             "Run a specific version",
+		Use:               "exec VERSION [--] [ARGS...]",
+		ValidArgsFunction: execValidArgs,
+	}
+)
+
+func init() {
+	// Adds exec as a child command of tfsw
+	rootCmd.AddCommand(execCmd)
+
+	// NOTE: Everything after the version belongs to Terraform, so stop
+	// parsing flags once it's been found
+	execCmd.Flags().SetInterspersed(false)
+}
+
+// execRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `exec` command
+func execRun(cmd *cobra.Command, args []string) {
+	ver, err := resolveVersion(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to resolve %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	tfArgs := args[1:]
+	if len(tfArgs) > 0 && tfArgs[0] == "--" {
+		tfArgs = tfArgs[1:]
+	}
+
+	if err := execVersion(ver, tfArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running %s %s: %v\n", config.Product.Title, ver, err)
+		os.Exit(1)
+	}
+}
+
+// execValidArgs only allows completion of the version, as everything
+// after it is passed to Terraform
+func execValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) >= 1 {
+		return []string{}, cobra.ShellCompDirectiveDefault
+	}
+
+	return selectValidArgs(cmd, args, toComplete)
+}
+
+// execVersion installs the version if it's missing, then runs it with the
+// given arguments. The active version is left untouched. It only returns
+// if the version can't be installed or run
+func execVersion(ver *Version, args []string) error {
+	if err := newVersion(ver); err != nil && !errors.Is(err, ErrVersionExists) {
+		return err
+	}
+
+	return execBinary(config.Product.versionBinary(ver), args)
+}
@@ -0,0 +1,59 @@
+//go:build windows
+// +build windows
+
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// execBinary runs the binary as a child process connected to our stdin,
+// stdout, and stderr, then exits with its exit code as Windows can't
+// replace the running process. It only returns if the binary can't be run
+func execBinary(bin string, args []string) error {
+	c := exec.Command(bin, args...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	// NOTE: Ctrl+C is delivered to every process on the console, so it's
+	// left for the child to handle rather than killing us first
+	signal.Ignore(os.Interrupt)
+
+	err := c.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	os.Exit(0)
+	return nil
+}
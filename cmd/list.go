@@ -23,6 +23,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"runtime"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
@@ -31,12 +32,11 @@ import (
 var (
 	listCmd = &cobra.Command{
 		Aliases: []string{"ls"},
-		Long:    "Lists all currently installed versions in the cache, and marks the active version",
+		Long:    "Lists all currently installed versions of every product, or only the product given with --product, and marks the active versions. With --remote lists every version of the product published for this platform",
 		Run:     listRun,
 		Short:   "List installed versions",
 		Use:     "list",
 	}
-	releaseNotesURL = "https://github.com/hashicorp/terraform/releases/tag/v%s"
 )
 
 func init() {
@@ -44,16 +44,41 @@ func init() {
 	rootCmd.AddCommand(listCmd)
 
 	// Add any extra command line flags for list here
-	listCmd.Flags().BoolP("remote", "r", false, "list ")
+	listCmd.Flags().BoolP("remote", "r", false, "List versions available to install")
 }
 
 // listRun is passed directly to the Cobra Run argument and executes
 // the primary logic for the `list` command
 func listRun(cmd *cobra.Command, args []string) {
-	err := listVersions(config.InstalledVersions, config.CurrentVersion)
+	var err error
+
+	// NOTE: Every product is listed unless one was chosen, whether with
+	// the flag, the environment or a config file
+	ps := []*product{config.Product}
+	if s, _ := lookupSetting("product"); configSource(cmd, s) == "default" {
+		ps = nil
+		for _, n := range productNames() {
+			ps = append(ps, products[n])
+		}
+	}
+
+	remote, _ := cmd.Flags().GetBool("remote")
+	if remote {
+		err = listRemoteVersions(config.InstalledVersions, config.CurrentVersion)
+	} else {
+		err = listVersions(ps)
+	}
+
 	switch err {
+	case ErrNoneAvailable:
+		fmt.Printf("No versions of %s are available for %s/%s\n", config.Product.Title, runtime.GOOS, runtime.GOARCH)
+		os.Exit(0)
 	case ErrNoneInstalled:
-		fmt.Printf("No versions of Terraform have been installed with %s\n", basename)
+		if len(ps) == 1 {
+			fmt.Printf("No versions of %s have been installed with %s\n", config.Product.Title, basename)
+		} else {
+			fmt.Printf("No versions have been installed with %s\n", basename)
+		}
 		os.Exit(0)
 	case nil:
 		os.Exit(0)
@@ -63,24 +88,78 @@ func listRun(cmd *cobra.Command, args []string) {
 	}
 }
 
-// listVersions takes the currently installed versions, and the current
-// active version, and prints out a pretty table
-func listVersions(inst []string, cur string) error {
-	if t := len(inst); t == 0 {
+// listVersions takes the products to list, and prints out a pretty table
+// of their installed versions, marking the active version of each
+func listVersions(ps []*product) error {
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Product", "Version", "Active", "Release Notes"})
+
+	var rows int
+	for _, p := range ps {
+		inst, err := installedVersions(p)
+		if err != nil {
+			return err
+		}
+
+		shim, err := shimLinked(p)
+		if err != nil {
+			return err
+		}
+
+		cur, err := activeVersion(p, shim, inst)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range inst {
+			rn := p.releaseNotesURL(v)
+
+			if v.Equal(cur) {
+				tw.AppendRow(table.Row{p.Name, v, "true", rn})
+			} else {
+				tw.AppendRow(table.Row{p.Name, v, "", rn})
+			}
+			rows++
+		}
+	}
+
+	if rows == 0 {
 		return ErrNoneInstalled
 	}
 
+	fmt.Println(tw.Render())
+	return nil
+}
+
+// listRemoteVersions fetches the release index, and prints out a pretty
+// table of every version available for this platform, marking those that
+// are installed and the current active version
+func listRemoteVersions(inst versions, cur *Version) error {
+	idx, err := loadIndex()
+	if err != nil {
+		return err
+	}
+
+	av := idx.available()
+	if t := len(av); t == 0 {
+		return ErrNoneAvailable
+	}
+
 	tw := table.NewWriter()
-	tw.AppendHeader(table.Row{"Version", "Active", "Release Notes"})
-	for _, v := range inst {
-		rn := fmt.Sprintf(releaseNotesURL, v)
+	tw.AppendHeader(table.Row{"Version", "Installed", "Active", "Release Notes"})
+	for _, v := range av {
+		rn := config.Product.releaseNotesURL(v)
+
+		var installed, active string
+		if inst.contains(v) {
+			installed = "true"
+		}
 
-		if v == cur {
-			tw.AppendRow(table.Row{v, "true", rn})
-			continue
+		if v.Equal(cur) {
+			active = "true"
 		}
 
-		tw.AppendRow(table.Row{v, "", rn})
+		tw.AppendRow(table.Row{v, installed, active, rn})
 	}
 
 	fmt.Println(tw.Render())
@@ -0,0 +1,70 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"tfsw/internal/utils"
+)
+
+const (
+	defaultLockTimeout time.Duration = 2 * time.Minute
+	lockFileName       string        = ".lock"
+)
+
+var (
+	// storeMu serialises the store lock within this process, as several
+	// versions can be installed at once
+	storeMu sync.Mutex
+)
+
+// lockStore takes the lock guarding changes to the installed versions and
+// the terraform symlink, so tfsw processes running at the same time, e.g.
+// parallel CI steps, don't undo each other's work. It returns a function
+// releasing the lock
+func lockStore() (func(), error) {
+	storeMu.Lock()
+
+	if err := os.MkdirAll(config.StoreDirectory, 0755); err != nil {
+		storeMu.Unlock()
+		return nil, err
+	}
+
+	l, err := utils.AcquireLock(filepath.Join(config.StoreDirectory, lockFileName), config.LockTimeout, func(pid int) {
+		fmt.Fprintf(os.Stderr, "Waiting for lock held by PID %d\n", pid)
+	})
+	if err != nil {
+		storeMu.Unlock()
+		return nil, err
+	}
+
+	return func() {
+		if err := l.Release(); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to release lock: %v\n", err)
+		}
+		storeMu.Unlock()
+	}, nil
+}
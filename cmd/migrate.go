@@ -0,0 +1,266 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"tfsw/internal/utils"
+)
+
+// legacyStoreDirs returns where older releases of tfsw installed versions
+// of a product, newest first. That was the config directory for
+// Terraform, and a directory for each product below it for the rest. On
+// macOS the store was then kept in the config directory too, in versions
+func legacyStoreDirs(p *product) []string {
+	var dirs []string
+	if runtime.GOOS == "darwin" {
+		dirs = append(dirs, filepath.Join(legacyDataDir(), p.Name))
+	}
+
+	if p.Name == defaultProduct {
+		return append(dirs, config.ConfigDirectory)
+	}
+
+	return append(dirs, filepath.Join(config.ConfigDirectory, p.Name))
+}
+
+// legacyDataDir returns where the store was kept on macOS before it was
+// moved out of the config directory
+func legacyDataDir() string {
+	return filepath.Join(config.ConfigDirectory, "versions")
+}
+
+// legacyEntries returns the paths of the installed versions, and the
+// default version files, left in a product's legacy stores
+func legacyEntries(p *product) ([]string, error) {
+	var paths []string
+	for _, old := range legacyStoreDirs(p) {
+		if old == p.storeDir() {
+			continue
+		}
+
+		dirs, err := os.ReadDir(old)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, d := range dirs {
+			switch {
+			case d.IsDir():
+				if _, err := parseVersion(d.Name()); err == nil {
+					paths = append(paths, filepath.Join(old, d.Name()))
+				}
+			case d.Name() == defaultVersionFile:
+				paths = append(paths, filepath.Join(old, d.Name()))
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// migrateStore moves versions installed by older releases of tfsw, which
+// kept them in the config directory, into the store. Symlinks pointing at
+// the versions moved are updated to match. It's run by every command, but
+// once the legacy store is empty there's nothing left to do
+func migrateStore() error {
+	pending := false
+	for _, n := range productNames() {
+		names, err := legacyEntries(products[n])
+		if err != nil {
+			return err
+		}
+		pending = pending || len(names) > 0
+	}
+
+	if !pending {
+		return nil
+	}
+
+	unlock, err := lockStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var moved int
+	for _, n := range productNames() {
+		p := products[n]
+
+		// NOTE: The entries are found again under the lock, as another
+		// process may have already moved them
+		paths, err := legacyEntries(p)
+		if err != nil {
+			return err
+		}
+
+		// NOTE: The newest legacy store comes first, so its default
+		// version file is the one kept
+		for _, src := range paths {
+			dst := filepath.Join(p.storeDir(), filepath.Base(src))
+			if err := moveEntry(src, dst); err != nil {
+				return err
+			}
+
+			if filepath.Base(src) != defaultVersionFile {
+				moved++
+			}
+		}
+
+		if len(paths) > 0 {
+			if err := relinkMigrated(p); err != nil {
+				return err
+			}
+		}
+
+		for _, dir := range legacyStoreDirs(p) {
+			if dir != config.ConfigDirectory {
+				// NOTE: Only removed if it's now empty
+				os.Remove(dir)
+			}
+		}
+	}
+
+	// NOTE: Older releases staged installs in the config directory too,
+	// and they're only removed if nothing was left behind in them
+	os.Remove(filepath.Join(config.ConfigDirectory, ".staging"))
+	if runtime.GOOS == "darwin" && legacyDataDir() != config.StoreDirectory {
+		os.Remove(filepath.Join(legacyDataDir(), ".staging"))
+		os.Remove(filepath.Join(legacyDataDir(), lockFileName))
+		os.Remove(legacyDataDir())
+	}
+
+	if moved > 0 {
+		fmt.Fprintf(os.Stderr, "Moved %d installed versions from %s to %s\n", moved, config.ConfigDirectory, config.StoreDirectory)
+	}
+
+	return nil
+}
+
+// moveEntry moves an installed version, or a file, from the legacy store
+// into the store. If it's already in the store the legacy copy is removed.
+// Moving between filesystems falls back to copying into the store's
+// staging directory first, so a partial copy is never seen as installed
+func moveEntry(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return os.RemoveAll(src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+		return os.Remove(src)
+	}
+
+	staged, err := newStagingDir(filepath.Base(src))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staged)
+
+	if err := copyTree(src, staged); err != nil {
+		return err
+	}
+
+	if err := commitStaged(staged, dst); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+// copyTree copies the files in src into dst, keeping their permissions
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(out, 0755)
+		case !info.Mode().IsRegular():
+			return fmt.Errorf("%s is not a regular file", p)
+		}
+
+		if err := copyFile(p, out); err != nil {
+			return err
+		}
+
+		return os.Chmod(out, info.Mode().Perm())
+	})
+}
+
+// relinkMigrated points a product's symlink at the store, if it pointed
+// at a version in the legacy store
+func relinkMigrated(p *product) error {
+	link, err := os.Readlink(p.symlink())
+	if err != nil {
+		// NOTE: There may never have been a version selected
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	legacy := false
+	for _, dir := range legacyStoreDirs(p) {
+		legacy = legacy || filepath.Dir(filepath.Dir(link)) == dir
+	}
+
+	if !legacy {
+		return nil
+	}
+
+	ver, err := parseVersion(filepath.Base(filepath.Dir(link)))
+	if err != nil {
+		return nil
+	}
+
+	return utils.Symlink(p.versionBinary(ver), p.symlink())
+}
@@ -0,0 +1,113 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"runtime"
+	"strings"
+)
+
+const (
+	defaultRepositoryURL string = "https://releases.hashicorp.com"
+	defaultURLTemplate   string = "{base}/{product}/{version}/{file}"
+)
+
+// repositoryURL returns the base URL of the repository releases are
+// downloaded from, which is the product's own unless a mirror is set
+func repositoryURL() string {
+	if config.RepositoryURL != "" {
+		return config.RepositoryURL
+	}
+
+	return config.Product.RepositoryURL
+}
+
+// artifactURL returns the URL of a file belonging to a release. Mirrors
+// using the same layout as releases.hashicorp.com only need the base URL
+// changing, others can set a URL template using the placeholders:
+//
+//	{base}     the repository base URL
+//	{product}  the product name e.g. terraform
+//	{version}  the version being installed e.g. 1.5.7
+//	{file}     the file name e.g. terraform_1.5.7_SHA256SUMS
+//	{os}       the operating system e.g. linux
+//	{arch}     the architecture e.g. amd64
+func artifactURL(ver, file string) string {
+	tmpl := config.URLTemplate
+	if tmpl == "" && config.RepositoryURL == "" {
+		tmpl = config.Product.URLTemplate
+	}
+
+	if tmpl == "" {
+		tmpl = defaultURLTemplate
+	}
+
+	return strings.NewReplacer(
+		"{base}", strings.TrimSuffix(repositoryURL(), "/"),
+		"{product}", config.Product.Name,
+		"{version}", ver,
+		"{file}", file,
+		"{os}", runtime.GOOS,
+		"{arch}", runtime.GOARCH,
+	).Replace(tmpl)
+}
+
+// indexURL returns the URL of the release index. Unless set explicitly,
+// or published elsewhere by the product, it's found alongside the
+// releases, as it is on releases.hashicorp.com
+func indexURL() string {
+	if config.IndexURL != "" {
+		return config.IndexURL
+	}
+
+	if config.RepositoryURL == "" && config.Product.IndexURL != "" {
+		return config.Product.IndexURL
+	}
+
+	return strings.TrimSuffix(repositoryURL(), "/") + "/" + config.Product.Name + "/index.json"
+}
+
+// validateRepositoryURL checks the repository base URL is something tfsw
+// can download from, or a local directory
+func validateRepositoryURL(s string) error {
+	// NOTE: A local directory isn't checked for existence here, as it
+	// may be removable media that's only needed when installing
+	if _, ok := localPath(s); ok {
+		return nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid mirror URL: %v", s, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q is not a valid mirror URL, it must start with http://, https://, or file://, or be an absolute path", s)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("%q is not a valid mirror URL, it has no host", s)
+	}
+
+	return nil
+}
@@ -21,12 +21,13 @@ with this program.  If not, see <https://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path"
 	"path/filepath"
 	"runtime"
-	"strings"
+	"sort"
+	"sync"
 
 	"github.com/spf13/cobra"
 	"tfsw/internal/utils"
@@ -34,73 +35,342 @@ import (
 
 var (
 	newCmd = &cobra.Command{
-		Args:   cobra.MinimumNArgs(1),
-		Long:   "Installs the specified Terraform versions to the local cache",
-		PreRun: validateVersion,
-		Run:    newRun,
-		Short:  "Install new versions",
-		Use:    "new VERSION...",
+		Long:              "Installs the specified versions of the selected product to the local cache. A version can be exact, a constraint e.g. \"~> 1.5\", latest, latest:PREFIX, or latest-prerelease. Without a version, the version pinned by .terraform-version or .tool-versions is used, followed by the required_version of the Terraform configuration in the current directory",
+		Run:               newRun,
+		Short:             "Install new versions",
+		Use:               "new [VERSION...]",
+		ValidArgsFunction: newValidArgs,
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(newCmd)
+
+	// Add any extra command line flags for new here
+	newCmd.Flags().String("from-zip", "", "Install from a release zip on disk, rather than from the repository")
+	newCmd.Flags().IntP("jobs", "j", 4, "How many versions to install at once")
+	newCmd.Flags().String("sig", "", "Signature of the SHA256SUMS file for --from-zip. Defaults to SUMS.sig")
+	newCmd.Flags().String("sums", "", "SHA256SUMS file to verify --from-zip against. Defaults to the one alongside the zip")
 }
 
 // newRun is passed directly to the Cobra Run argument and executes
 // the primary logic for the `new` command
 func newRun(cmd *cobra.Command, args []string) {
-	for _, version := range args {
-		err := newVersion(version)
-		switch err {
-		case ErrVersionExists:
-			fmt.Printf("Terraform %s already exists\n", version)
-		case nil:
-			fmt.Printf("Terraform %s has been added\n", version)
-		default:
-			fmt.Fprintf(os.Stderr, "Encountered an unhandled error: %v\n", err)
+	if zip, _ := cmd.Flags().GetString("from-zip"); zip != "" {
+		if len(args) > 0 {
+			fmt.Fprintln(os.Stderr, "Versions can't be given alongside --from-zip")
+			os.Exit(1)
+		}
+
+		sums, _ := cmd.Flags().GetString("sums")
+		sig, _ := cmd.Flags().GetString("sig")
+
+		ver, src, err := zipSource(zip, sums, sig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to install from %s: %v\n", zip, err)
+			os.Exit(1)
+		}
+
+		newReport(ver, installVersion(ver, src))
+		os.Exit(0)
+	}
+
+	if len(args) == 0 {
+		detected, src, err := detectVersion(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "No version given, and unable to detect one: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Using %s from %s\n", detected, src)
+		args = []string{detected}
+	}
+
+	var vers versions
+	for _, arg := range args {
+		ver, err := resolveVersion(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to resolve %s: %v\n", arg, err)
 			os.Exit(1)
 		}
+
+		if !vers.contains(ver) {
+			vers = append(vers, ver)
+		}
+	}
+
+	if len(vers) == 1 {
+		newReport(vers[0], newVersion(vers[0]))
+		os.Exit(0)
+	}
+
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	errs := newVersions(vers, jobs)
+
+	var added, existing, failed int
+	for i, ver := range vers {
+		msg, ok := newOutcome(ver, errs[i])
+		switch {
+		case !ok:
+			fmt.Fprintln(os.Stderr, msg)
+			failed++
+		case errs[i] == nil:
+			added++
+		default:
+			existing++
+		}
+	}
+
+	fmt.Printf("%d added, %d already existed, %d failed\n", added, existing, failed)
+	if failed > 0 {
+		os.Exit(1)
 	}
 	os.Exit(0)
 }
 
-// newVersion takes a Terraform version number, downloads it,
-// checks shasums, then unzips it into the correct location
-func newVersion(ver string) error {
-	if _, err := os.Stat(filepath.Join(config.ConfigDirectory, ver, terraform)); err == nil {
-		return ErrVersionExists
+// newReport tells the user the outcome of installing a version, exiting
+// if it failed
+func newReport(ver *Version, err error) {
+	msg, ok := newOutcome(ver, err)
+	if !ok {
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(1)
 	}
 
-	os.MkdirAll(config.TempDirectory, 0755)
-	defer os.RemoveAll(config.TempDirectory)
+	fmt.Println(msg)
+}
 
-	zip := strings.Join([]string{"terraform", ver, runtime.GOOS, runtime.GOARCH}, "_") + ".zip"
-	sums := strings.Join([]string{"terraform", ver, "SHA256SUMS"}, "_")
-	urlRoot := path.Join(config.RepositoryDomain, "terraform", ver)
+// newOutcome describes the outcome of installing a version, and reports
+// whether it was successful
+func newOutcome(ver *Version, err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrVersionExists):
+		return fmt.Sprintf("%s %s already exists", config.Product.Title, ver), true
+	case errors.Is(err, utils.ErrChecksumMismatch):
+		return fmt.Sprintf("%s %s has not been added as the download is corrupt or has been tampered with: %v", config.Product.Title, ver, err), false
+	case errors.Is(err, utils.ErrArchiveRejected):
+		return fmt.Sprintf("%s %s has not been added as its archive is unsafe to extract: %v", config.Product.Title, ver, err), false
+	case errors.Is(err, utils.ErrSignatureInvalid):
+		return fmt.Sprintf("%s %s has not been added as its SHA256SUMS could not be verified: %v", config.Product.Title, ver, err), false
+	case err == nil:
+		return fmt.Sprintf("%s %s has been added", config.Product.Title, ver), true
+	default:
+		return fmt.Sprintf("%s %s has not been added: %v", config.Product.Title, ver, err), false
+	}
+}
+
+// newValidArgs offers the versions in the cached release index that
+// aren't already installed, or given as an argument
+func newValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	idx := cachedIndex()
+	if idx == nil {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var given versions
+	for i := range args {
+		if v, err := parseVersion(args[i]); err == nil {
+			given = append(given, v)
+		}
+	}
+
+	validArgs := idx.available().without(config.InstalledVersions...).without(given...)
+	sort.Sort(sort.Reverse(validArgs))
+
+	return validArgs.strings(), cobra.ShellCompDirectiveNoFileComp
+}
 
-	err := utils.FetchUrl("https://"+path.Join(urlRoot, zip), filepath.Join(config.TempDirectory, zip))
+// releaseArchiveLimits returns the limits for extracting a release of the
+// product, which contains the binary and a few small files such as a
+// licence
+func releaseArchiveLimits(p *product) utils.ArchiveLimits {
+	// NOTE: The binary is around 100MB so 1GB leaves plenty of room for
+	// growth
+	return utils.ArchiveLimits{
+		Allowed:    append([]string{p.binary()}, p.ArchiveFiles...),
+		MaxBytes:   1 << 30,
+		MaxEntries: 8,
+	}
+}
+
+// newVersion takes a version number, downloads it, verifies
+// the signature of the shasums, checks shasums, then unzips it into a
+// staging directory. Once the staged install has been checked it's moved
+// into the correct location in one step, so an interrupted install never
+// leaves a partial version behind
+func newVersion(v *Version) error {
+	return installVersion(v, releaseSource())
+}
+
+// fetchRelease fetches the SHA256SUMS for a version, along with the given
+// release archives, into dir. The signature of the SHA256SUMS is verified,
+// then the checksum of each archive. An archive that doesn't match is
+// quarantined
+func fetchRelease(src source, ver, dir string, archives ...string) error {
+	sums := config.Product.sumsName(ver)
+	err := src.fetch(ver, sums, filepath.Join(dir, sums))
 	if err != nil {
 		return err
 	}
 
-	err = utils.FetchUrl("https://"+path.Join(urlRoot, sums), filepath.Join(config.TempDirectory, sums))
+	if err := verifySums(src, ver, dir, sums); err != nil {
+		return err
+	}
+
+	for _, archive := range archives {
+		err := src.fetch(ver, archive, filepath.Join(dir, archive))
+		if err != nil {
+			return fmt.Errorf("unable to fetch %s: %w", archive, err)
+		}
+
+		ok, err := utils.Sha256sum(filepath.Join(dir, sums), filepath.Join(dir, archive))
+		if !ok || err != nil {
+			if errors.Is(err, utils.ErrChecksumMismatch) {
+				if qerr := quarantine(filepath.Join(dir, archive)); qerr != nil {
+					fmt.Fprintf(os.Stderr, "Unable to remove %s: %v\n", archive, qerr)
+				}
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newVersions installs several versions at once, using at most jobs
+// workers, with the progress of each shown on its own line. It returns the
+// error from installing each version, in the same order
+func newVersions(vers versions, jobs int) []error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	display := newProgressDisplay()
+	lines := make([]*progressLine, len(vers))
+	for i, ver := range vers {
+		lines[i] = display.line(ver.String(), "waiting")
+	}
+
+	// NOTE: Extraction is quick, and a progress bar for it would be drawn
+	// over the display, so it's hidden until every install is complete
+	newProgress := utils.NewProgress
+	utils.NewProgress = func(string) utils.Progress { return utils.Discard }
+	defer func() { utils.NewProgress = newProgress }()
+
+	errs := make([]error, len(vers))
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs && w < len(vers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				lines[i].start("installing")
+
+				src := releaseSource()
+				if _, ok := src.(httpSource); ok {
+					src = httpSource{progress: lines[i]}
+				}
+
+				errs[i] = installVersion(vers[i], src)
+				switch {
+				case errors.Is(errs[i], ErrVersionExists):
+					lines[i].start("already exists")
+				case errs[i] != nil:
+					lines[i].start("failed")
+				default:
+					lines[i].start("added")
+				}
+			}
+		}()
+	}
+
+	for i := range vers {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	return errs
+}
+
+// installVersion installs a version of the product using the release files
+// from the given source, see newVersion
+func installVersion(v *Version, src source) error {
+	ver := v.String()
+	if _, err := os.Stat(config.Product.versionBinary(v)); err == nil {
+		return ErrVersionExists
+	}
+
+	// NOTE: Each install has its own temporary directory, so several can
+	// run at once
+	if err := os.MkdirAll(config.TempDirectory, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp(config.TempDirectory, ver+"-")
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(tmp)
 
-	ok, err := utils.Sha256sum(filepath.Join(config.TempDirectory, sums), filepath.Join(config.TempDirectory, zip))
-	if !ok && err != nil {
+	archive := config.Product.archiveName(ver, runtime.GOOS, runtime.GOARCH)
+	if err := fetchRelease(src, ver, tmp, archive); err != nil {
 		return err
 	}
 
-	versionDir := filepath.Join(config.ConfigDirectory, ver)
-	os.MkdirAll(versionDir, 0755)
+	if err := cleanupStaging(); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to clean up old staging directories: %v\n", err)
+	}
 
-	_, err = utils.Unzip(filepath.Join(config.TempDirectory, zip), versionDir)
+	staged, err := newStagingDir(ver)
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(staged)
 
-	return nil
+	if config.Product.binaryArchive() {
+		err = stageBinary(filepath.Join(tmp, archive), staged)
+	} else {
+		_, err = utils.Unzip(filepath.Join(tmp, archive), staged, releaseArchiveLimits(config.Product))
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := verifyStaged(staged); err != nil {
+		return err
+	}
+
+	unlock, err := lockStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// NOTE: Another process may have installed the same version while
+	// this one was downloading it
+	if _, err := os.Stat(config.Product.versionBinary(v)); err == nil {
+		return ErrVersionExists
+	}
+
+	return commitStaged(staged, config.Product.versionDir(v))
+}
+
+// quarantine moves a download that failed verification out of the way,
+// so nothing can mistake it for a good download, then deletes it
+func quarantine(file string) error {
+	dir := filepath.Join(config.CacheDirectory, "quarantine")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(dir, fmt.Sprintf("%s.%d", filepath.Base(file), os.Getpid()))
+	if err := os.Rename(file, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(dst)
 }
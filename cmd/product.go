@@ -0,0 +1,471 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	archiveBinary          string = "binary"
+	archiveZip             string = "zip"
+	defaultArchiveTemplate string = "{product}_{version}_{os}_{arch}.zip"
+	defaultBinaryTemplate  string = "{product}_{version}_{os}_{arch}{exe}"
+	defaultProduct         string = "terraform"
+	defaultSumsTemplate    string = "{product}_{version}_SHA256SUMS"
+	productNameExpr        string = `^[a-z][a-z0-9_-]*$`
+)
+
+var (
+	productNameRegex *regexp.Regexp = regexp.MustCompile(productNameExpr)
+
+	// products are the tools tfsw can manage, by name. More can be added,
+	// or these changed, in the products tables of the config file, see
+	// defineProducts
+	products = map[string]*product{
+		"consul": hashicorpProduct("consul", "Consul"),
+		"nomad":  hashicorpProduct("nomad", "Nomad"),
+		"packer": hashicorpProduct("packer", "Packer"),
+		"terraform": {
+			ArchiveFiles:    []string{"LICENSE.txt"},
+			Binary:          "terraform",
+			KeyedSignatures: true,
+			Keys:            hashicorpKey,
+			Name:            "terraform",
+			ReleaseNotesURL: "https://github.com/hashicorp/terraform/releases/tag/v{version}",
+			RepositoryURL:   defaultRepositoryURL,
+			RequiredVersion: true,
+			SignatureSuffix: ".sig",
+			Title:           "Terraform",
+			ToolName:        "terraform",
+			VersionFile:     ".terraform-version",
+		},
+		"tofu": {
+			ArchiveFiles:    []string{"CHANGELOG.md", "LICENSE", "README.md"},
+			Binary:          "tofu",
+			IndexURL:        "https://get.opentofu.org/tofu/api.json",
+			Keys:            opentofuKey,
+			Name:            "tofu",
+			ReleaseNotesURL: "https://github.com/opentofu/opentofu/releases/tag/v{version}",
+			RepositoryURL:   "https://github.com/opentofu/opentofu/releases/download",
+			RequiredVersion: true,
+			SignatureSuffix: ".gpgsig",
+			Title:           "OpenTofu",
+			ToolName:        "opentofu",
+			URLTemplate:     "{base}/v{version}/{file}",
+			VersionFile:     ".opentofu-version",
+		},
+		"vault": hashicorpProduct("vault", "Vault"),
+	}
+)
+
+// product is a tool tfsw can manage versions of. Each product's versions
+// are stored separately, and each has its own symlink in the binary
+// directory pointing at its active version
+type product struct {
+	// ArchiveFiles are the files, other than the binary, that may be
+	// found in a release archive
+	ArchiveFiles []string
+
+	// ArchiveFormat is how the binary is packaged in a release, either
+	// zip, or binary when the release file is the executable itself.
+	// Defaults to zip
+	ArchiveFormat string
+
+	// ArchiveTemplate is the template for the name of a release archive,
+	// see archiveName
+	ArchiveTemplate string
+
+	// Binary is the name of the executable in each release, without any
+	// .exe suffix, and of the symlink to the active version
+	Binary string
+
+	// IndexURL is where the release index is published, when it's not
+	// alongside the releases in the repository
+	IndexURL string
+
+	// KeyedSignatures reports whether a signature is published for each
+	// signing key, as well as the current one, see verifySums
+	KeyedSignatures bool
+
+	// KeyRingPath is an armored key ring file trusted to sign releases of
+	// the product, alongside Keys
+	KeyRingPath string
+
+	// Keys are the armored public keys trusted to sign releases. Products
+	// without any must have their keys added with --keyring
+	Keys []byte
+
+	// Name is the name releases are published under, as used in release
+	// file names and URLs
+	Name string
+
+	// ReleaseNotesURL is the template for the URL of the release notes of
+	// a version, with {version} replaced by the version
+	ReleaseNotesURL string
+
+	// RepositoryURL is where releases are downloaded from, unless a mirror
+	// has been configured
+	RepositoryURL string
+
+	// RequiredVersion reports whether the required_version of the
+	// configuration in a directory applies to the product
+	RequiredVersion bool
+
+	// SignatureSuffix is added to the name of a SHA256SUMS file to get
+	// the name of its detached signature
+	SignatureSuffix string
+
+	// SumsTemplate is the template for the name of the SHA256SUMS file of
+	// a release, see sumsName
+	SumsTemplate string
+
+	// Title is the name of the product shown to users
+	Title string
+
+	// ToolName is the name of the product in asdf .tool-versions files
+	ToolName string
+
+	// Unsigned reports whether the product's SHA256SUMS files aren't
+	// signed, in which case only the checksums are verified
+	Unsigned bool
+
+	// URLTemplate is the template for release file URLs in the product's
+	// own repository. Mirrors use the releases.hashicorp.com layout
+	URLTemplate string
+
+	// VersionFile is the tfenv style file pinning the version of the
+	// product e.g. .terraform-version
+	VersionFile string
+}
+
+// hashicorpProduct returns a product published on releases.hashicorp.com
+// in the same way as Terraform
+func hashicorpProduct(name, title string) *product {
+	return &product{
+		ArchiveFiles:    []string{"LICENSE.txt"},
+		Binary:          name,
+		KeyedSignatures: true,
+		Keys:            hashicorpKey,
+		Name:            name,
+		ReleaseNotesURL: "https://github.com/hashicorp/" + name + "/releases/tag/v{version}",
+		RepositoryURL:   defaultRepositoryURL,
+		SignatureSuffix: ".sig",
+		Title:           title,
+		ToolName:        name,
+		VersionFile:     "." + name + "-version",
+	}
+}
+
+// defineProducts adds the products defined in the config file, or changes
+// the built in ones, from tables named after each product:
+//
+//	[products.terragrunt]
+//	archive_format = "binary"
+//	archive_name = "{product}_{os}_{arch}{exe}"
+//	repository_url = "https://github.com/gruntwork-io/terragrunt/releases/download"
+//	signed = false
+//	sums_name = "SHA256SUMS"
+//	url_template = "{base}/v{version}/{file}"
+//
+// A new product is assumed to be laid out like releases.hashicorp.com
+// unless told otherwise
+func defineProducts(file map[string]string) error {
+	settings := map[string]map[string]string{}
+	for k, v := range file {
+		if !strings.HasPrefix(k, "products.") {
+			continue
+		}
+
+		rest := strings.TrimPrefix(k, "products.")
+		i := strings.LastIndex(rest, ".")
+		if i == -1 {
+			return fmt.Errorf("%s is not a valid setting, expected products.NAME.SETTING", k)
+		}
+
+		name := rest[:i]
+		if !productNameRegex.MatchString(name) {
+			return fmt.Errorf("%q is not a valid product name, it must be lowercase letters, numbers, - or _", name)
+		}
+
+		if settings[name] == nil {
+			settings[name] = map[string]string{}
+		}
+		settings[name][rest[i+1:]] = v
+	}
+
+	names := make([]string, 0, len(settings))
+	for n := range settings {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		p := &product{
+			Binary:          n,
+			Name:            n,
+			RepositoryURL:   defaultRepositoryURL,
+			SignatureSuffix: ".sig",
+			Title:           n,
+			ToolName:        n,
+			VersionFile:     "." + n + "-version",
+		}
+		if b, ok := products[n]; ok {
+			c := *b
+			p = &c
+		}
+
+		if err := p.set(settings[n]); err != nil {
+			return fmt.Errorf("products.%s: %v", n, err)
+		}
+
+		products[n] = p
+	}
+
+	// NOTE: Each product's active version is a symlink named after its
+	// binary, so no two can share one
+	binaries := map[string]string{}
+	for _, n := range productNames() {
+		b := products[n].Binary
+		if o, ok := binaries[b]; ok {
+			return fmt.Errorf("products %s and %s both use the binary %s", o, n, b)
+		}
+		binaries[b] = n
+	}
+
+	return nil
+}
+
+// set applies the settings from a product's table in the config file
+func (p *product) set(settings map[string]string) error {
+	for k, v := range settings {
+		switch k {
+		case "archive_files":
+			p.ArchiveFiles = nil
+			for _, f := range strings.Split(v, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					p.ArchiveFiles = append(p.ArchiveFiles, f)
+				}
+			}
+		case "archive_format":
+			if v != archiveZip && v != archiveBinary {
+				return fmt.Errorf("archive_format must be %s or %s, not %q", archiveZip, archiveBinary, v)
+			}
+			p.ArchiveFormat = v
+		case "archive_name":
+			p.ArchiveTemplate = v
+		case "binary":
+			if v == "" || strings.ContainsAny(v, `/\`) {
+				return fmt.Errorf("%q is not a valid binary name", v)
+			}
+			p.Binary = v
+		case "index_url":
+			p.IndexURL = v
+		case "keyring":
+			p.KeyRingPath = v
+		case "release_notes_url":
+			p.ReleaseNotesURL = v
+		case "repository_url":
+			if err := validateRepositoryURL(v); err != nil {
+				return err
+			}
+			p.RepositoryURL = v
+		case "signature_suffix":
+			p.SignatureSuffix = v
+		case "signed":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("signed must be true or false, not %q", v)
+			}
+			p.Unsigned = !b
+		case "sums_name":
+			p.SumsTemplate = v
+		case "title":
+			p.Title = v
+		case "tool_name":
+			p.ToolName = v
+		case "url_template":
+			p.URLTemplate = v
+		case "version_file":
+			p.VersionFile = v
+		default:
+			return fmt.Errorf("unknown setting %q", k)
+		}
+	}
+
+	return nil
+}
+
+// lookupProduct returns the product with the given name
+func lookupProduct(name string) (*product, error) {
+	if name == "opentofu" {
+		name = "tofu"
+	}
+
+	p, ok := products[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown product %q, expected one of %s", name, strings.Join(productNames(), ", "))
+	}
+
+	return p, nil
+}
+
+// productNames returns the names of every product, sorted
+func productNames() []string {
+	var names []string
+	for n := range products {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// binary returns the file name of the product's executable on this
+// platform
+func (p *product) binary() string {
+	return p.Binary + exeSuffix
+}
+
+// storeDir returns the directory the product's versions are installed
+// into
+func (p *product) storeDir() string {
+	return filepath.Join(config.StoreDirectory, p.Name)
+}
+
+// versionDir returns the directory a version of the product is installed
+// into
+func (p *product) versionDir(ver *Version) string {
+	return filepath.Join(p.storeDir(), ver.String())
+}
+
+// versionBinary returns the path to the executable of an installed
+// version of the product
+func (p *product) versionBinary(ver *Version) string {
+	return filepath.Join(p.versionDir(ver), p.binary())
+}
+
+// symlink returns the path of the symlink to the product's active version
+func (p *product) symlink() string {
+	return filepath.Join(config.BinaryDirectory, p.binary())
+}
+
+// releaseNotesURL returns the URL of the release notes for a version, or
+// an empty string if the product doesn't publish any
+func (p *product) releaseNotesURL(ver *Version) string {
+	return strings.ReplaceAll(p.ReleaseNotesURL, "{version}", ver.String())
+}
+
+// binaryArchive reports whether the product's release files are the
+// executable itself rather than a zip
+func (p *product) binaryArchive() bool {
+	return p.ArchiveFormat == archiveBinary
+}
+
+// archiveTemplate returns the template for the name of a release archive.
+// The placeholders are {product}, {version}, {os}, {arch}, and {exe},
+// which is .exe for Windows releases
+func (p *product) archiveTemplate() string {
+	switch {
+	case p.ArchiveTemplate != "":
+		return p.ArchiveTemplate
+	case p.binaryArchive():
+		return defaultBinaryTemplate
+	}
+
+	return defaultArchiveTemplate
+}
+
+// archiveName returns the name of a release archive
+func (p *product) archiveName(ver, goos, goarch string) string {
+	exe := ""
+	if goos == "windows" {
+		exe = ".exe"
+	}
+
+	return strings.NewReplacer(
+		"{product}", p.Name,
+		"{version}", ver,
+		"{os}", goos,
+		"{arch}", goarch,
+		"{exe}", exe,
+	).Replace(p.archiveTemplate())
+}
+
+// sumsName returns the name of the SHA256SUMS file for a release
+func (p *product) sumsName(ver string) string {
+	tmpl := p.SumsTemplate
+	if tmpl == "" {
+		tmpl = defaultSumsTemplate
+	}
+
+	return strings.NewReplacer("{product}", p.Name, "{version}", ver).Replace(tmpl)
+}
+
+// parseArchiveName returns the version, OS, and architecture of a release
+// archive from its name. Templates without an OS or architecture are taken
+// to be for this platform. It reports false if the name doesn't match
+func (p *product) parseArchiveName(name string) (ver, goos, goarch string, ok bool) {
+	re := p.archiveRegex()
+	m := re.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", "", false
+	}
+
+	group := func(g, def string) string {
+		if i := re.SubexpIndex(g); i != -1 {
+			return m[i]
+		}
+		return def
+	}
+
+	ver = group("version", "")
+	if ver == "" {
+		return "", "", "", false
+	}
+
+	return ver, group("os", runtime.GOOS), group("arch", runtime.GOARCH), true
+}
+
+// archiveRegex matches the name of a release archive, capturing the
+// version, OS, and architecture in named groups
+func (p *product) archiveRegex() *regexp.Regexp {
+	expr := strings.NewReplacer(
+		`\{product\}`, regexp.QuoteMeta(p.Name),
+		`\{version\}`, `(?P<version>.+)`,
+		`\{os\}`, `(?P<os>[a-z0-9]+)`,
+		`\{arch\}`, `(?P<arch>[a-z0-9]+)`,
+		`\{exe\}`, `(?:\.exe)?`,
+	).Replace(regexp.QuoteMeta(p.archiveTemplate()))
+
+	return regexp.MustCompile(`^` + expr + `$`)
+}
+
+// versionEnv returns the environment variable that picks the version in
+// shim mode e.g. TFSW_TERRAFORM_VERSION
+func (p *product) versionEnv() string {
+	return "TFSW_" + strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_")) + "_VERSION"
+}
@@ -0,0 +1,156 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressInterval is the shortest time between redraws of a
+	// progressDisplay, so a fast download doesn't flood the terminal
+	progressInterval time.Duration = 100 * time.Millisecond
+)
+
+// progressDisplay shows the progress of several installs at once, one per
+// line. On a terminal the lines are redrawn in place, otherwise a line is
+// only printed when an install moves to its next stage
+type progressDisplay struct {
+	drawn    int
+	lines    []*progressLine
+	mu       sync.Mutex
+	out      io.Writer
+	redrawn  time.Time
+	terminal bool
+}
+
+// progressLine is the progress of a single install within a
+// progressDisplay. It implements utils.Progress, counting the bytes
+// downloaded
+type progressLine struct {
+	bytes   int64
+	display *progressDisplay
+	name    string
+	status  string
+}
+
+// newProgressDisplay returns a display writing to stdout
+func newProgressDisplay() *progressDisplay {
+	d := &progressDisplay{out: os.Stdout}
+	if info, err := os.Stdout.Stat(); err == nil {
+		d.terminal = info.Mode()&os.ModeCharDevice != 0
+	}
+
+	return d
+}
+
+// line adds a line to the display, for the install called name
+func (d *progressDisplay) line(name, status string) *progressLine {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	l := &progressLine{display: d, name: name, status: status}
+	d.lines = append(d.lines, l)
+	d.draw(l, true)
+	return l
+}
+
+// draw updates the display after l has changed. Unless forced, as when
+// the status of a line changes, redraws are limited to one every
+// progressInterval. The caller must hold d.mu
+func (d *progressDisplay) draw(l *progressLine, force bool) {
+	if !d.terminal {
+		if force {
+			fmt.Fprintln(d.out, l.String())
+		}
+		return
+	}
+
+	if !force && time.Since(d.redrawn) < progressInterval {
+		return
+	}
+	d.redrawn = time.Now()
+
+	var b strings.Builder
+	if d.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", d.drawn)
+	}
+
+	for _, l := range d.lines {
+		fmt.Fprintf(&b, "\r\x1b[2K%s\n", l)
+	}
+
+	d.drawn = len(d.lines)
+	fmt.Fprint(d.out, b.String())
+}
+
+// String formats the line for display
+func (l *progressLine) String() string {
+	if l.bytes == 0 {
+		return fmt.Sprintf("%-16s %s", l.name, l.status)
+	}
+
+	return fmt.Sprintf("%-16s %s (%s)", l.name, l.status, formatBytes(l.bytes))
+}
+
+// start changes the status of the line, resetting the bytes downloaded
+func (l *progressLine) start(status string) {
+	l.display.mu.Lock()
+	defer l.display.mu.Unlock()
+
+	l.status, l.bytes = status, 0
+	l.display.draw(l, true)
+}
+
+func (l *progressLine) Write(b []byte) (int, error) {
+	l.display.mu.Lock()
+	defer l.display.mu.Unlock()
+
+	l.bytes += int64(len(b))
+	l.display.draw(l, false)
+	return len(b), nil
+}
+
+// Clear does nothing, as the line stays on screen once complete
+func (l *progressLine) Clear() error {
+	return nil
+}
+
+// formatBytes returns a byte count in the largest whole unit, e.g. 1.5 MB
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,251 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"tfsw/internal/utils"
+)
+
+// releaseIndex mirrors the index.json published for each product on the
+// releases site, e.g. https://releases.hashicorp.com/terraform/index.json
+type releaseIndex struct {
+	Name     string             `json:"name"`
+	Versions map[string]release `json:"versions"`
+}
+
+// release is a single published version within the releaseIndex
+type release struct {
+	Builds            []build  `json:"builds"`
+	Name              string   `json:"name"`
+	Shasums           string   `json:"shasums"`
+	ShasumsSignature  string   `json:"shasums_signature"`
+	ShasumsSignatures []string `json:"shasums_signatures"`
+	Version           string   `json:"version"`
+}
+
+// build is a single downloadable artifact for an OS and architecture
+type build struct {
+	Arch     string `json:"arch"`
+	Filename string `json:"filename"`
+	Name     string `json:"name"`
+	OS       string `json:"os"`
+	URL      string `json:"url"`
+	Version  string `json:"version"`
+}
+
+// tofuIndex mirrors the release index published by OpenTofu at
+// https://get.opentofu.org/tofu/api.json, which lists the files in each
+// release rather than describing each build
+type tofuIndex struct {
+	Versions []struct {
+		Files []string `json:"files"`
+		ID    string   `json:"id"`
+	} `json:"versions"`
+}
+
+// indexCache is the on disk representation of the release index, stored
+// with the time it was fetched and the validators needed to revalidate it
+type indexCache struct {
+	Fetched    time.Time        `json:"fetched"`
+	Index      *releaseIndex    `json:"index"`
+	URL        string           `json:"url"`
+	Validators utils.Validators `json:"validators"`
+}
+
+// indexCachePath returns the location of the cached release index
+func indexCachePath() string {
+	return filepath.Join(config.CacheDirectory, config.Product.Name+"-index.json")
+}
+
+// loadIndex returns the release index for the product. The cached copy is
+// used while it's younger than the configured TTL, after which it's
+// revalidated against the repository. If the repository can't be reached
+// the last snapshot is used instead. A local repository is always read
+// directly
+func loadIndex() (*releaseIndex, error) {
+	if dir, ok := localRepository(); ok {
+		return localIndex(dir)
+	}
+
+	c, err := readIndexCache()
+	if err != nil {
+		return nil, err
+	}
+
+	if c != nil && !config.Refresh && time.Since(c.Fetched) < config.CacheTTL {
+		return c.Index, nil
+	}
+
+	var v utils.Validators
+	if c != nil {
+		v = c.Validators
+	}
+
+	body, v, err := utils.FetchIfModified(indexURL(), v)
+	switch {
+	case errors.Is(err, utils.ErrNotModified):
+		c.Fetched = time.Now()
+		return c.Index, writeIndexCache(c)
+	case err != nil && c != nil:
+		fmt.Fprintf(os.Stderr, "Unable to refresh the release index, using the copy from %s: %v\n", c.Fetched.Format(time.RFC1123), err)
+		return c.Index, nil
+	case err != nil:
+		return nil, err
+	}
+
+	idx, err := parseIndex(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx, writeIndexCache(&indexCache{Fetched: time.Now(), Index: idx, URL: indexURL(), Validators: v})
+}
+
+// parseIndex parses a release index in either the releases.hashicorp.com
+// or the OpenTofu format, which has a list of versions rather than a map
+func parseIndex(b []byte) (*releaseIndex, error) {
+	var raw struct {
+		Versions json.RawMessage `json:"versions"`
+	}
+
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	if v := bytes.TrimSpace(raw.Versions); len(v) == 0 || v[0] != '[' {
+		idx := &releaseIndex{}
+		return idx, json.Unmarshal(b, idx)
+	}
+
+	ti := &tofuIndex{}
+	if err := json.Unmarshal(b, ti); err != nil {
+		return nil, err
+	}
+
+	name := config.Product.Name
+	idx := &releaseIndex{Name: name, Versions: map[string]release{}}
+	for _, v := range ti.Versions {
+		r := release{Name: name, Shasums: config.Product.sumsName(v.ID), Version: v.ID}
+		for _, f := range v.Files {
+			if ver, goos, goarch, ok := config.Product.parseArchiveName(f); ok && ver == v.ID {
+				r.Builds = append(r.Builds, build{Arch: goarch, Filename: f, Name: name, OS: goos, Version: v.ID})
+			}
+		}
+
+		idx.Versions[v.ID] = r
+	}
+
+	return idx, nil
+}
+
+// cachedIndex returns the cached release index without going to the
+// network, regardless of its age. It returns nil if there's no cache
+func cachedIndex() *releaseIndex {
+	c, err := readIndexCache()
+	if err != nil || c == nil {
+		return nil
+	}
+
+	return c.Index
+}
+
+// readIndexCache reads the cached release index. It returns nil if the
+// cache doesn't exist yet, can't be understood, or came from a different
+// repository
+func readIndexCache() (*indexCache, error) {
+	b, err := os.ReadFile(indexCachePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	c := &indexCache{}
+	if err := json.Unmarshal(b, c); err != nil || c.Index == nil || c.URL != indexURL() {
+		// NOTE: A corrupt cache is treated as a missing one, it'll be
+		// replaced on the next successful fetch
+		return nil, nil
+	}
+
+	return c, nil
+}
+
+// writeIndexCache writes the release index to the cache directory. It's
+// written to a temporary file first so readers never see a partial write
+func writeIndexCache(c *indexCache) error {
+	if err := os.MkdirAll(config.CacheDirectory, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	tmp := indexCachePath() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, indexCachePath())
+}
+
+// hasBuild reports whether the release has an artifact for the given OS
+// and architecture
+func (r release) hasBuild(goos, goarch string) bool {
+	for _, b := range r.Builds {
+		if b.OS == goos && b.Arch == goarch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// available returns the versions in the index that have a build for the
+// current OS and architecture, and that tfsw knows how to handle
+func (idx *releaseIndex) available() versions {
+	var av versions
+	for s, r := range idx.Versions {
+		v, err := parseVersion(s)
+		if err != nil {
+			continue
+		}
+
+		if r.hasBuild(runtime.GOOS, runtime.GOARCH) {
+			av = append(av, v)
+		}
+	}
+
+	sort.Sort(av)
+	return av
+}
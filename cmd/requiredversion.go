@@ -0,0 +1,271 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// requiredVersion reads the Terraform configuration in dir and returns
+// the required_version constraints from every `terraform` block, combined
+// so that they all must be satisfied. It returns ErrNoRequiredVersion if
+// none are found
+func requiredVersion(dir string) (string, error) {
+	var files []string
+	for _, glob := range []string{"*.tf", "*.tf.json"} {
+		m, err := filepath.Glob(filepath.Join(dir, glob))
+		if err != nil {
+			return "", err
+		}
+		files = append(files, m...)
+	}
+
+	sort.Strings(files)
+
+	var found []string
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+
+		var rv []string
+		if strings.HasSuffix(f, ".json") {
+			rv, err = requiredVersionJSON(b)
+		} else {
+			rv, err = requiredVersionHCL(string(b))
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("unable to parse %s: %v", f, err)
+		}
+
+		for _, c := range rv {
+			if _, err := parseConstraints(c); err != nil {
+				return "", fmt.Errorf("%s: %w", f, err)
+			}
+		}
+
+		found = append(found, rv...)
+	}
+
+	if len(found) == 0 {
+		return "", ErrNoRequiredVersion
+	}
+
+	return strings.Join(found, ", "), nil
+}
+
+// requiredVersionJSON returns the required_version attributes from a
+// configuration file written in Terraform's JSON syntax, where the
+// terraform block can be either an object or an array of objects
+func requiredVersionJSON(b []byte) ([]string, error) {
+	var doc struct {
+		Terraform json.RawMessage `json:"terraform"`
+	}
+
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Terraform) == 0 {
+		return nil, nil
+	}
+
+	type block struct {
+		RequiredVersion string `json:"required_version"`
+	}
+
+	var blocks []block
+	if err := json.Unmarshal(doc.Terraform, &blocks); err != nil {
+		var b block
+		if err := json.Unmarshal(doc.Terraform, &b); err != nil {
+			return nil, err
+		}
+		blocks = []block{b}
+	}
+
+	var rv []string
+	for _, b := range blocks {
+		if b.RequiredVersion != "" {
+			rv = append(rv, b.RequiredVersion)
+		}
+	}
+
+	return rv, nil
+}
+
+// requiredVersionHCL returns the required_version attributes from the
+// top level `terraform` blocks of a configuration file written in HCL.
+// It's not a full HCL parser, it only understands enough of the syntax to
+// reliably skip over comments, strings, heredocs and nested blocks
+func requiredVersionHCL(src string) ([]string, error) {
+	toks, err := hclTokens(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []string
+	depth := 0
+	inTerraform := false
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch {
+		case t == "{":
+			depth++
+		case t == "}":
+			depth--
+			if depth == 0 {
+				inTerraform = false
+			}
+		case depth == 0 && t == "terraform" && i+1 < len(toks) && toks[i+1] == "{":
+			inTerraform = true
+		case depth == 1 && inTerraform && t == "required_version" && i+2 < len(toks) && toks[i+1] == "=":
+			v := toks[i+2]
+			if !strings.HasPrefix(v, `"`) {
+				return nil, fmt.Errorf("required_version must be a string, got %s", v)
+			}
+			rv = append(rv, strings.Trim(v, `"`))
+			i += 2
+		}
+	}
+
+	return rv, nil
+}
+
+// hclTokens splits HCL source into a flat list of tokens. Comments and
+// heredocs are dropped, strings are returned with their quotes so they
+// can be told apart from identifiers, and all other punctuation is
+// returned one character at a time
+func hclTokens(src string) ([]string, error) {
+	var toks []string
+	r := []rune(src)
+	for i := 0; i < len(r); i++ {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+		case c == '#' || (c == '/' && i+1 < len(r) && r[i+1] == '/'):
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			end := i + 3
+			for end < len(r) && !(r[end-1] == '*' && r[end] == '/') {
+				end++
+			}
+			if end >= len(r) {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i = end
+		case c == '<' && i+1 < len(r) && r[i+1] == '<':
+			n, err := skipHeredoc(r, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, `"heredoc"`)
+			i = n
+		case c == '"':
+			n, err := skipString(r, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, string(r[i:n+1]))
+			i = n
+		case c == '_' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			start := i
+			for i+1 < len(r) && (r[i+1] == '_' || r[i+1] == '-' || r[i+1] == '.' || unicode.IsLetter(r[i+1]) || unicode.IsDigit(r[i+1])) {
+				i++
+			}
+			toks = append(toks, string(r[start:i+1]))
+		default:
+			toks = append(toks, string(c))
+		}
+	}
+
+	return toks, nil
+}
+
+// skipString takes the index of an opening quote and returns the index of
+// the matching closing quote, stepping over escapes and any quotes nested
+// inside ${...} interpolations
+func skipString(r []rune, i int) (int, error) {
+	for i++; i < len(r); i++ {
+		switch {
+		case r[i] == '\\':
+			i++
+		case r[i] == '"':
+			return i, nil
+		case r[i] == '\n':
+			return 0, fmt.Errorf("unterminated string")
+		case (r[i] == '$' || r[i] == '%') && i+1 < len(r) && r[i+1] == '{':
+			depth := 0
+			for i++; i < len(r); i++ {
+				if r[i] == '"' {
+					n, err := skipString(r, i)
+					if err != nil {
+						return 0, err
+					}
+					i = n
+					continue
+				}
+
+				if r[i] == '{' {
+					depth++
+				}
+
+				if r[i] == '}' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("unterminated string")
+}
+
+// skipHeredoc takes the index of a heredoc's opening << and returns the
+// index of the last character of its closing marker
+func skipHeredoc(r []rune, i int) (int, error) {
+	lines := strings.SplitAfter(string(r[i:]), "\n")
+	marker := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(lines[0], "<<"), "-"))
+	if marker == "" {
+		return 0, fmt.Errorf("heredoc has no marker")
+	}
+
+	n := i + len([]rune(lines[0]))
+	for _, l := range lines[1:] {
+		n += len([]rune(l))
+		if strings.TrimSpace(l) == marker {
+			return n - 1, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unterminated heredoc %s", marker)
+}
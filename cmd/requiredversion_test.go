@@ -0,0 +1,268 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRequiredVersionHCL(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		invalid bool
+	}{
+		{
+			name: "simple",
+			src:  "terraform {\n  required_version = \">= 1.3\"\n}\n",
+			want: []string{">= 1.3"},
+		},
+		{
+			name: "nested blocks are ignored",
+			src: `terraform {
+  required_providers {
+    aws = {
+      source           = "hashicorp/aws"
+      required_version = "0.1.0"
+    }
+  }
+  required_version = "~> 1.5.0"
+}
+`,
+			want: []string{"~> 1.5.0"},
+		},
+		{
+			name: "other blocks are ignored",
+			src: `locals {
+  required_version = "1.0.0"
+}
+
+module "terraform" {
+  source = "./terraform"
+}
+`,
+		},
+		{
+			name: "comments",
+			src: `# required_version = "1.0.0"
+// terraform { required_version = "1.1.0" }
+/* terraform {
+  required_version = "1.2.0"
+} */
+terraform {
+  required_version = ">= 1.3" # not 1.4.0
+}
+`,
+			want: []string{">= 1.3"},
+		},
+		{
+			name: "heredoc",
+			src: `locals {
+  doc = <<EOT
+}
+terraform {
+  required_version = "1.0.0"
+EOT
+}
+
+terraform {
+  required_version = "1.5.7"
+}
+`,
+			want: []string{"1.5.7"},
+		},
+		{
+			name: "indented heredoc",
+			src: `locals {
+  doc = <<-EOT
+    }
+    EOT
+}
+
+terraform {
+  required_version = "1.5.7"
+}
+`,
+			want: []string{"1.5.7"},
+		},
+		{
+			name: "interpolated strings",
+			src: `locals {
+  a = "${lookup(var.m, "}")}"
+  b = "%{ if var.x }{%{ endif }"
+  c = "escaped \" quote {"
+}
+
+terraform {
+  required_version = "1.5.7"
+}
+`,
+			want: []string{"1.5.7"},
+		},
+		{
+			name: "several terraform blocks",
+			src: `terraform {
+  required_version = ">= 1.3"
+}
+
+terraform {
+  required_version = "< 1.6"
+}
+`,
+			want: []string{">= 1.3", "< 1.6"},
+		},
+		{
+			name:    "not a string",
+			src:     "terraform {\n  required_version = var.version\n}\n",
+			invalid: true,
+		},
+		{
+			name:    "unterminated string",
+			src:     "terraform {\n  required_version = \">= 1.3\n}\n",
+			invalid: true,
+		},
+		{
+			name:    "unterminated heredoc",
+			src:     "locals {\n  doc = <<EOT\n}\n",
+			invalid: true,
+		},
+		{
+			name:    "unterminated comment",
+			src:     "/* terraform {\n",
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := requiredVersionHCL(tt.src)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("requiredVersionHCL() = %q, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("requiredVersionHCL() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requiredVersionHCL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredVersionJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		invalid bool
+	}{
+		{name: "object", src: `{"terraform": {"required_version": ">= 1.3"}}`, want: []string{">= 1.3"}},
+		{name: "array", src: `{"terraform": [{"required_version": ">= 1.3"}, {"required_version": "< 1.6"}]}`, want: []string{">= 1.3", "< 1.6"}},
+		{name: "no terraform block", src: `{"locals": {"required_version": "1.0.0"}}`},
+		{name: "no required_version", src: `{"terraform": {"backend": {}}}`},
+		{name: "invalid", src: `{"terraform": `, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := requiredVersionJSON([]byte(tt.src))
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("requiredVersionJSON() = %q, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("requiredVersionJSON() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requiredVersionJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+		err   error
+	}{
+		{
+			name: "combined across files",
+			files: map[string]string{
+				"main.tf":          "terraform {\n  required_version = \">= 1.3\"\n}\n",
+				"versions.tf.json": `{"terraform": {"required_version": "< 1.6"}}`,
+			},
+			want: ">= 1.3, < 1.6",
+		},
+		{
+			name:  "none",
+			files: map[string]string{"main.tf": "locals {}\n", "README.md": "required_version"},
+			err:   ErrNoRequiredVersion,
+		},
+		{
+			name:  "invalid constraint",
+			files: map[string]string{"main.tf": "terraform {\n  required_version = \">= one\"\n}\n"},
+			err:   ErrVersionInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, src := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := requiredVersion(dir)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("requiredVersion() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("requiredVersion() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("requiredVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	latestAllowed string = "latest-allowed"
+	minRequired   string = "min-required"
+)
+
+// resolveVersion takes a version expression given on the command line and
+// resolves it to a single version. An exact version is returned as is,
+// anything else is resolved to the highest matching version available
+// remotely, or installed locally when offline. Accepted expressions are:
+//
+//	1.5.7                 an exact version
+//	~> 1.5                Terraform's constraint syntax
+//	>= 1.3, < 1.6         multiple constraints that must all match
+//	latest                the newest final release
+//	latest:1.4            the newest final release starting 1.4
+//	latest:^1\.4          the newest final release matching a tfenv regex
+//	latest-prerelease     the newest release, including pre-releases
+//	latest-allowed        the newest release allowed by required_version
+//	min-required          the oldest release allowed by required_version
+//
+// The last two are tfenv keywords, and use the configuration in the
+// current directory
+func resolveVersion(expr string) (*Version, error) {
+	if v, err := parseVersion(expr); err == nil {
+		return v, nil
+	}
+
+	match, err := versionMatcher(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := candidateVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	return pickMatch(candidates, match, expr)
+}
+
+// detectVersion works out which version expression to use when one isn't
+// given on the command line. A pin file in dir, or any of its parents, is
+// preferred over the required_version of the configuration in dir. It
+// returns the expression along with a description of where it came from
+func detectVersion(dir string) (string, string, error) {
+	expr, file, err := versionFile(dir)
+	if err == nil {
+		return expr, file, nil
+	}
+
+	if !errors.Is(err, ErrNoVersionFile) {
+		return "", "", err
+	}
+
+	if !config.Product.RequiredVersion {
+		return "", "", fmt.Errorf("no %s or %s found", config.Product.VersionFile, toolVersionsFile)
+	}
+
+	expr, err = requiredVersion(dir)
+	if errors.Is(err, ErrNoRequiredVersion) {
+		return "", "", fmt.Errorf("no %s, %s, or required_version found", config.Product.VersionFile, toolVersionsFile)
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return expr, "required_version", nil
+}
+
+// versionMatcher returns a function reporting whether a version matches
+// the given expression
+func versionMatcher(expr string) (func(*Version) bool, error) {
+	switch {
+	case expr == "latest":
+		return func(v *Version) bool { return !v.Prerelease() }, nil
+	case expr == "latest-prerelease":
+		return func(v *Version) bool { return true }, nil
+	case expr == latestAllowed, expr == minRequired:
+		rv, err := requiredVersion(".")
+		if errors.Is(err, ErrNoRequiredVersion) {
+			return nil, fmt.Errorf("%w in the current directory for %s", ErrNoRequiredVersion, expr)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		cs, err := parseConstraints(rv)
+		if err != nil {
+			return nil, err
+		}
+
+		return cs.check, nil
+	case strings.HasPrefix(expr, "latest:"):
+		prefix := strings.TrimPrefix(expr, "latest:")
+		if _, err := parseConstraints(prefix); err == nil && !strings.ContainsAny(prefix, "=<>~!,-") {
+			return func(v *Version) bool {
+				return !v.Prerelease() && strings.HasPrefix(v.String()+".", prefix+".")
+			}, nil
+		}
+
+		// NOTE: Anything that isn't a version prefix is treated as a
+		// regex, the same as tfenv, so existing pin files keep working
+		re, err := regexp.Compile(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a valid version prefix or regex", ErrVersionInvalid, prefix)
+		}
+
+		return func(v *Version) bool { return re.MatchString(v.String()) }, nil
+	}
+
+	cs, err := parseConstraints(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return cs.check, nil
+}
+
+// candidateVersions returns the versions a version expression is resolved
+// against. This is the release index, or the installed versions when
+// running with --offline
+func candidateVersions() (versions, error) {
+	if config.Offline {
+		return config.InstalledVersions, nil
+	}
+
+	idx, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.available(), nil
+}
+
+// pickMatch returns the version that matches the expression, which is the
+// lowest for min-required and the highest for anything else
+func pickMatch(candidates versions, match func(*Version) bool, expr string) (*Version, error) {
+	if expr == minRequired {
+		return lowestMatch(candidates, match, expr)
+	}
+
+	return highestMatch(candidates, match, expr)
+}
+
+// lowestMatch returns the lowest version that matches, or
+// ErrNoMatchingVersion if there are none
+func lowestMatch(candidates versions, match func(*Version) bool, expr string) (*Version, error) {
+	var lowest *Version
+	for _, v := range candidates {
+		if match(v) && (lowest == nil || v.LessThan(lowest)) {
+			lowest = v
+		}
+	}
+
+	if lowest == nil {
+		return nil, fmt.Errorf("%w %q", ErrNoMatchingVersion, expr)
+	}
+
+	return lowest, nil
+}
+
+// highestMatch returns the highest version that matches, or
+// ErrNoMatchingVersion if there are none
+func highestMatch(candidates versions, match func(*Version) bool, expr string) (*Version, error) {
+	var highest *Version
+	for _, v := range candidates {
+		if match(v) && (highest == nil || highest.LessThan(v)) {
+			highest = v
+		}
+	}
+
+	if highest == nil {
+		return nil, fmt.Errorf("%w %q", ErrNoMatchingVersion, expr)
+	}
+
+	return highest, nil
+}
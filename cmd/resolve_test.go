@@ -0,0 +1,119 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveRequiredKeywords(t *testing.T) {
+	available := []string{"1.2.9", "1.3.0", "1.3.4", "1.5.7", "1.6.0-rc1", "1.6.0", "1.7.2"}
+
+	tests := []struct {
+		name     string
+		required string
+		pin      string
+		want     string
+		err      error
+	}{
+		{name: "latest-allowed lower bound", required: ">= 1.3", pin: latestAllowed, want: "1.7.2"},
+		{name: "latest-allowed range", required: ">= 1.3, < 1.6", pin: latestAllowed, want: "1.5.7"},
+		{name: "latest-allowed pessimistic", required: "~> 1.3.0", pin: latestAllowed, want: "1.3.4"},
+		{name: "latest-allowed exact", required: "1.3.0", pin: latestAllowed, want: "1.3.0"},
+		{name: "min-required lower bound", required: ">= 1.3", pin: minRequired, want: "1.3.0"},
+		{name: "min-required exclusive bound", required: "> 1.3.0", pin: minRequired, want: "1.3.4"},
+		{name: "min-required pessimistic", required: "~> 1.5", pin: minRequired, want: "1.5.7"},
+		{name: "min-required skips pre-releases", required: ">= 1.5.8", pin: minRequired, want: "1.6.0"},
+		{name: "no matching version", required: ">= 2.0", pin: latestAllowed, err: ErrNoMatchingVersion},
+		{name: "no required_version", pin: minRequired, err: ErrNoRequiredVersion},
+	}
+
+	product, offline, installed := config.Product, config.Offline, config.InstalledVersions
+	defer func() {
+		config.Product, config.Offline, config.InstalledVersions = product, offline, installed
+	}()
+
+	// NOTE: Offline, versions are resolved against the installed ones
+	config.Product = products[defaultProduct]
+	config.Offline = true
+	config.InstalledVersions = nil
+	for _, s := range available {
+		v, err := parseVersion(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.InstalledVersions = append(config.InstalledVersions, v)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, config.Product.VersionFile), []byte(tt.pin+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.required != "" {
+				src := "terraform {\n  required_version = \"" + tt.required + "\"\n}\n"
+				if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(src), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(wd)
+
+			expr, _, err := detectVersion(".")
+			if err != nil {
+				t.Fatalf("detectVersion() error = %v", err)
+			}
+
+			if expr != tt.pin {
+				t.Fatalf("detectVersion() = %q, want %q", expr, tt.pin)
+			}
+
+			v, err := resolveVersion(expr)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("resolveVersion(%q) error = %v, want %v", expr, err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("resolveVersion(%q) error = %v", expr, err)
+			}
+
+			if v.String() != tt.want {
+				t.Errorf("resolveVersion(%q) = %s, want %s", expr, v, tt.want)
+			}
+		})
+	}
+}
@@ -26,33 +26,64 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 const (
-	expr string = `^([0-9]+\.){2}[0-9]+(-(alpha|beta|oci|rc)[0-9]*)?$`
+	defaultCacheTTL time.Duration = time.Hour
+	expr            string        = `^([0-9]+)\.([0-9]+)\.([0-9]+)(-(alpha|beta|oci|rc)([0-9]*))?$`
 )
 
 var (
-	basename                          = filepath.Base(os.Args[0])
-	config                            = &configuration{}
-	ErrNoneInstalled   error          = errors.New("no versions installed")
-	ErrVersionNotExist error          = errors.New("file or directory doesn't exist")
-	ErrVersionExists   error          = errors.New("version already exists")
-	ErrVersionSame     error          = errors.New("new version is the same as old version")
-	regex              *regexp.Regexp = regexp.MustCompile(expr)
-	rootCmd                           = &cobra.Command{
-		Long:  "Terraform Switch allows adding, removing, and switching, between multiple versions of Terraform",
-		Short: "tfsw manages Terraform versions",
-		Use:   "tfsw",
-	}
-	versionCurrent    string
-	versionsInstalled []string
+	basename             = filepath.Base(os.Args[0])
+	config               = &configuration{}
+	loadErr              error
+	ErrInstallInvalid    error          = errors.New("install failed verification")
+	ErrNoDefaultVersion  error          = errors.New("no default version selected")
+	ErrNoMatchingVersion error          = errors.New("no version matches")
+	ErrNoRequiredVersion error          = errors.New("no required_version found")
+	ErrNoVersionFile     error          = errors.New("no version file found")
+	ErrNoneAvailable     error          = errors.New("no versions available")
+	ErrNoneInstalled     error          = errors.New("no versions installed")
+	ErrVersionNotExist   error          = errors.New("file or directory doesn't exist")
+	ErrVersionExists     error          = errors.New("version already exists")
+	ErrVersionInvalid    error          = errors.New("not a valid version")
+	ErrVersionSame       error          = errors.New("new version is the same as old version")
+	regex                *regexp.Regexp = regexp.MustCompile(expr)
+	rootCmd                             = &cobra.Command{
+		Long:             "Terraform Switch allows adding, removing, and switching, between multiple versions of Terraform and OpenTofu",
+		PersistentPreRun: validateConfig,
+		Short:            "tfsw manages Terraform and OpenTofu versions",
+		Use:              "tfsw",
+	}
 )
 
+func init() {
+	// Add any global command line flags here
+	rootCmd.PersistentFlags().StringVar(&config.BinaryDirectory, "bin-dir", "", "Directory the active version of each product is symlinked into. Defaults to ~/bin")
+	rootCmd.PersistentFlags().StringVar(&config.CacheDirectory, "cache-dir", "", "Directory downloads and the release index are cached in")
+	rootCmd.PersistentFlags().StringVar(&config.StoreDirectory, "store-dir", "", "Directory installed versions are stored in")
+	rootCmd.PersistentFlags().DurationVar(&config.CacheTTL, "cache-ttl", defaultCacheTTL, "How long the cached release index is used before it's refreshed")
+	rootCmd.PersistentFlags().DurationVar(&config.LockTimeout, "lock-timeout", defaultLockTimeout, "How long to wait for another tfsw process to finish changing the installed versions")
+	rootCmd.PersistentFlags().StringVar(&config.RepositoryURL, "mirror", "", "Base URL of the release repository, for mirrors laid out like "+defaultRepositoryURL)
+	rootCmd.PersistentFlags().StringVar(&config.URLTemplate, "url-template", "", "URL template for release files, for mirrors with a different layout e.g. "+defaultURLTemplate)
+	rootCmd.PersistentFlags().StringVar(&config.CABundle, "ca-bundle", "", "A PEM file of extra certificate authorities to trust, for mirrors and proxies using a private CA")
+	rootCmd.PersistentFlags().StringVar(&config.ClientCert, "client-cert", "", "A PEM client certificate for mirrors that require one, used with --client-key")
+	rootCmd.PersistentFlags().StringVar(&config.ClientKey, "client-key", "", "The PEM private key for --client-cert")
+	rootCmd.PersistentFlags().StringVar(&config.NetrcPath, "netrc", "", "A .netrc file with credentials for authenticated mirrors. Defaults to ~/.netrc")
+	rootCmd.PersistentFlags().StringVar(&config.KeyRingPath, "keyring", "", "An extra OpenPGP key ring trusted to sign releases, alongside the embedded HashiCorp key")
+	rootCmd.PersistentFlags().BoolVar(&config.Offline, "offline", false, "Resolve versions against those installed, rather than the release index")
+	rootCmd.PersistentFlags().StringVar(&config.ProductName, "product", "", "The product to manage, one of "+strings.Join(productNames(), ", ")+", or one defined in the config file. Defaults to "+defaultProduct)
+	rootCmd.PersistentFlags().BoolVar(&config.Refresh, "refresh", false, "Refresh the cached release index regardless of its age")
+	rootCmd.PersistentFlags().StringVar(&config.Verify, "verify", "", "How releases are verified, either "+verifySignature+" to check the signature and checksums, or "+verifyChecksum+" to only check the checksums. Defaults to "+verifySignature)
+	rootCmd.PersistentFlags().BoolVar(&config.SmokeTest, "smoke-test", false, "Run the version command of new installs before they're moved into place")
+}
+
 // TODO - If terraform is in path, but it's not TF, it allow add to work, but if terraform
 //  is then removed you can't get it to work without manually
 
@@ -60,55 +91,107 @@ func Execute() error {
 	// TODO: Detect if `terraform` is already on $PATH and exit
 	// TODO: CLI doesn't work without ~/.config/tfsw being created
 	// in advance
-	err := config.load()
-	if err != nil {
-		return err
+	// NOTE: Problems loading the configuration are reported once the
+	// command is known, as doctor and config set run regardless
+	loadErr = config.load()
+
+	if p := shimProduct(); p != nil {
+		if err := shimConfig(p); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", basename, err)
+			os.Exit(1)
+		}
+
+		if err := shimRun(os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", basename, err)
+			os.Exit(1)
+		}
 	}
 
 	return rootCmd.Execute()
 }
 
 type configuration struct {
-	BinaryDirectory        string
-	CacheDirectory         string
-	ConfigDirectory        string
-	CurrentVersion         string
-	HomeDirectory          string
-	InstalledVersions      []string
-	RepositoryDomain       string
-	TempDirectory          string
-	TerraformSymlinkTarget string
+	BinaryDirectory   string
+	CABundle          string
+	CacheDirectory    string
+	CacheTTL          time.Duration
+	ClientCert        string
+	ClientKey         string
+	ConfigDirectory   string
+	CurrentVersion    *Version
+	File              map[string]string
+	HomeDirectory     string
+	IndexURL          string
+	InstalledVersions versions
+	KeyRingPath       string
+	LockTimeout       time.Duration
+	NetrcPath         string
+	Offline           bool
+	Product           *product
+	ProductName       string
+	ProjectFile       string
+	ProjectValues     map[string]string
+	Refresh           bool
+	RepositoryURL     string
+	ShimMode          bool
+	SmokeTest         bool
+	StoreDirectory    string
+	SymlinkTarget     string
+	TempDirectory     string
+	URLTemplate       string
+	UserFile          string
+	UserValues        map[string]string
+	Verify            string
 }
 
+// load builds the configuration from the defaults, config files, and
+// environment. A step that fails doesn't stop the rest, so commands that
+// diagnose or repair the configuration can still run, and every problem
+// found is returned
 func (c *configuration) load() error {
-	if err := c.binDir(); err != nil {
-		return err
+	var errs configErrors
+	for _, step := range []func() error{c.binDir, c.confDir, c.cacheDir, c.storeDir} {
+		if err := step(); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	if err := c.confDir(); err != nil {
-		return err
-	}
+	c.transport()
+	c.product()
+	c.Verify = verifySignature
 
-	if err := c.cacheDir(); err != nil {
-		return err
+	for _, step := range []func() error{c.configFile, c.defineProducts, c.applySettings, c.tmpDir, c.loadProduct} {
+		if err := step(); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	if err := c.tmpDir(); err != nil {
-		return err
+	if len(errs) > 0 {
+		return errs
 	}
 
-	if err := c.currentVersion(); err != nil {
-		return err
+	return nil
+}
+
+// configErrors are the problems found loading the configuration
+type configErrors []error
+
+func (e configErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
 	}
 
-	c.TerraformSymlinkTarget = filepath.Join(c.BinaryDirectory, terraform)
-	c.RepositoryDomain = "releases.hashicorp.com"
+	return strings.Join(msgs, "; ")
+}
 
-	return nil
+// defineProducts adds the products defined in the config files
+func (c *configuration) defineProducts() error {
+	return defineProducts(c.File)
 }
 
 // binDir sets the directory the Terraform binaries will be symlinked
-// to. Defaults to ${HOME}/bin
+// to. Defaults to ${HOME}/bin, and can be set with bin_dir
 func (c *configuration) binDir() error {
 	if err := c.homeDir(); err != nil {
 		return err
@@ -124,6 +207,8 @@ func (c *configuration) binDir() error {
 //
 //	UNIX: ${HOME}/.cache/tfsw
 //	Windows: TBC
+//
+// It can be set with cache_dir
 func (c *configuration) cacheDir() error {
 	userCacheDir, err := os.UserCacheDir()
 	if err != nil {
@@ -135,9 +220,8 @@ func (c *configuration) cacheDir() error {
 	return nil
 }
 
-// confDir sets the directory to store permanent files e.g. Terraform
-// versions that have been downloaded, and configuration. Defaults to
-// the following:
+// confDir sets the directory to store permanent files e.g. the config
+// file. Defaults to the following:
 //
 //	UNIX: ${HOME}/.config/tfsw
 //	Windows: TBC
@@ -152,6 +236,68 @@ func (c *configuration) confDir() error {
 	return nil
 }
 
+// storeDir sets the directory versions that have been downloaded are
+// stored in, with a directory for each product. It's kept apart from the
+// config directory so binaries don't end up in dotfile syncs and backups.
+// Defaults to the following:
+//
+//	UNIX: ${XDG_DATA_HOME}/tfsw/versions
+//	macOS: ~/Library/Application Support/tfsw-data/versions
+//	Windows: %LocalAppData%\tfsw\versions
+//
+// It can be set with store_dir
+func (c *configuration) storeDir() error {
+	dataDir, err := userDataDir()
+	if err != nil {
+		return err
+	}
+
+	c.StoreDirectory = filepath.Join(dataDir, "versions")
+
+	return nil
+}
+
+// product sets which product is being managed. Defaults to terraform, and
+// can be set with product
+func (c *configuration) product() {
+	c.ProductName = defaultProduct
+}
+
+// loadProduct finds the state of the product being managed, its symlink,
+// whether it's in shim mode, and its installed and active versions. It's
+// run again once any command line flags have been applied, as --product
+// may have changed
+func (c *configuration) loadProduct() error {
+	p, err := lookupProduct(c.ProductName)
+	if err != nil {
+		// NOTE: Fall back to the default product so commands that
+		// diagnose the configuration still have one to look at
+		c.Product = products[defaultProduct]
+		c.SymlinkTarget = c.Product.symlink()
+		return err
+	}
+
+	c.Product = p
+	c.SymlinkTarget = p.symlink()
+
+	if err := c.shimMode(); err != nil {
+		return err
+	}
+
+	return c.currentVersion()
+}
+
+// transport sets the TLS and credential files used for downloads. None
+// are set by default, apart from ${HOME}/.netrc, and they can be set with
+// ca_bundle, client_cert, client_key, and netrc
+func (c *configuration) transport() {
+	netrc := ".netrc"
+	if runtime.GOOS == "windows" {
+		netrc = "_netrc"
+	}
+	c.NetrcPath = filepath.Join(c.HomeDirectory, netrc)
+}
+
 // homeDir returns the configured home directory. Defaults to the
 // users ${HOME}
 func (c *configuration) homeDir() error {
@@ -169,83 +315,211 @@ func (c *configuration) homeDir() error {
 // ${cacheDir}/tmp
 func (c *configuration) tmpDir() error {
 	if c.CacheDirectory == "" {
-		return fmt.Errorf("asdsda")
+		return errors.New("the cache directory is not set")
 	}
 
 	c.TempDirectory = filepath.Join(c.CacheDirectory, "tmp")
 	return nil
 }
 
-// currentVersion finds the active version of Terraform and adds
-// it to the configuration struct as CurrentVersion
+// shimMode detects whether the product's symlink points at tfsw itself
+// and adds it to the configuration struct as ShimMode
+func (c *configuration) shimMode() error {
+	shim, err := shimLinked(c.Product)
+	c.ShimMode = shim
+	return err
+}
+
+// currentVersion finds the installed and active versions of the product,
+// and adds them to the configuration struct as InstalledVersions and
+// CurrentVersion. In shim mode the active version is the default version
 func (c *configuration) currentVersion() error {
-	if err := c.installedVersions(); err != nil {
+	iv, err := installedVersions(c.Product)
+	if err != nil {
 		return err
 	}
 
-	if len(c.InstalledVersions) == 0 {
-		c.CurrentVersion = ""
-		return nil
+	c.InstalledVersions = iv
+	c.CurrentVersion, err = activeVersion(c.Product, c.ShimMode, iv)
+	return err
+}
+
+// shimLinked reports whether the symlink for a product points at tfsw
+// itself
+func shimLinked(p *product) (bool, error) {
+	link, err := filepath.EvalSymlinks(p.symlink())
+	if err != nil {
+		return false, nil
 	}
 
-	link, err := filepath.EvalSymlinks(filepath.Join(c.BinaryDirectory, terraform))
+	exe, err := executable()
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	return link == exe, nil
+}
+
+// activeVersion returns the active version of a product, or nil if there
+// isn't one. In shim mode this is the default version
+func activeVersion(p *product, shim bool, inst versions) (*Version, error) {
+	if len(inst) == 0 {
+		return nil, nil
 	}
 
-	var version string
-	for _, i := range c.InstalledVersions {
-		if strings.Contains(link, i) {
-			version = i
-			break
+	var v *Version
+	if shim {
+		var err error
+		if v, err = readDefaultVersion(p); err != nil || v == nil {
+			return nil, err
+		}
+	} else {
+		// NOTE: Versions can be installed with `new` before one has
+		// ever been selected, so there may not be a symlink yet
+		link, err := filepath.EvalSymlinks(p.symlink())
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// NOTE: The symlink points at ${storeDir}/${VERSION}/${binary}
+		// so the version is the name of the parent directory
+		if v, err = parseVersion(filepath.Base(filepath.Dir(link))); err != nil {
+			return nil, nil
 		}
 	}
 
-	c.CurrentVersion = version
-	return nil
+	if !inst.contains(v) {
+		return nil, nil
+	}
+
+	return v, nil
 }
 
-// installedVersions finds the currently installed versions of Terraform
-// and adds them to the configuration struct in InstalledVersions
-func (c *configuration) installedVersions() error {
-	fh, err := os.Open(c.ConfigDirectory)
+// installedVersions finds the installed versions of a product
+func installedVersions(p *product) (versions, error) {
+	fh, err := os.Open(p.storeDir())
 	if err != nil {
 		// NOTE: If this is the first time tfsw is being used the
-		// config dir won't exist yet
+		// store won't exist yet
 		if errors.Is(err, os.ErrNotExist) {
-			c.InstalledVersions = nil
-			return nil
+			return nil, nil
 		}
-		return err
+		return nil, err
 	}
 
 	defer fh.Close()
 
 	dirs, err := fh.ReadDir(0)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var iv []string
+	var iv versions
 	for _, d := range dirs {
-		if d.IsDir() {
-			if regex.MatchString(d.Name()) {
-				iv = append(iv, d.Name())
+		if !d.IsDir() {
+			continue
+		}
+
+		v, err := parseVersion(d.Name())
+		if err != nil {
+			continue
+		}
+
+		// NOTE: A directory without a binary is the remains of a failed
+		// install from an older tfsw, so isn't treated as installed
+		if _, err := os.Stat(p.versionBinary(v)); err == nil {
+			iv = append(iv, v)
+		}
+	}
+
+	sort.Sort(iv)
+	return iv, nil
+}
+
+// validateConfig is used by every command to check the configuration is
+// still valid once any command line flags have been applied
+func validateConfig(cmd *cobra.Command, args []string) {
+	if errs := checkConfig(true); len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", errs[0])
+		os.Exit(1)
+	}
+}
+
+// tolerateConfig is used instead of validateConfig by commands that must
+// run with a broken configuration, to diagnose or repair it
+func tolerateConfig(cmd *cobra.Command, args []string) {
+	checkConfig(false)
+}
+
+// checkConfig applies any command line flags to the configuration, and
+// returns every problem found with it, including any from loading it.
+// Versions left in the legacy store are only moved when migrate is set,
+// and nothing has been found wrong
+func checkConfig(migrate bool) []error {
+	var errs []error
+	add := func(err error) {
+		// NOTE: Loading the product is repeated once the flags have been
+		// applied, so the same problem can be found twice
+		for _, e := range errs {
+			if e.Error() == err.Error() {
+				return
 			}
 		}
+		errs = append(errs, err)
 	}
 
-	sort.Strings(iv)
-	c.InstalledVersions = iv
-	return nil
+	var cerrs configErrors
+	switch {
+	case errors.As(loadErr, &cerrs):
+		for _, err := range cerrs {
+			add(err)
+		}
+	case loadErr != nil:
+		add(loadErr)
+	}
+
+	if config.RepositoryURL != "" {
+		if err := validateRepositoryURL(config.RepositoryURL); err != nil {
+			add(err)
+		}
+	}
+
+	if config.Verify != verifySignature && config.Verify != verifyChecksum {
+		add(fmt.Errorf("--verify must be %s or %s, not %q", verifySignature, verifyChecksum, config.Verify))
+	}
+
+	// NOTE: --cache-dir may have moved the temporary directory
+	if err := config.tmpDir(); err != nil {
+		add(err)
+	}
+
+	if migrate && len(errs) == 0 {
+		if err := migrateStore(); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to move installed versions to %s: %v\n", config.StoreDirectory, err)
+		}
+	}
+
+	if err := config.loadProduct(); err != nil {
+		add(err)
+	}
+
+	if err := configureClient(); err != nil {
+		add(fmt.Errorf("unable to configure downloads: %w", err))
+	}
+
+	return errs
 }
 
 // validateVersion is used by commands to put some guard rails around
 // the version of Terraform we're downloading. It reads through any
-// arguments and validates them against a regex
+// arguments and validates that they parse as a version
 func validateVersion(cmd *cobra.Command, args []string) {
 	for i := range args {
-		if !regex.MatchString(args[i]) {
+		if _, err := parseVersion(args[i]); err != nil {
 			fmt.Fprintf(os.Stderr, "%s is not a valid version\n", args[i])
 			os.Exit(1)
 		}
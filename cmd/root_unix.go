@@ -23,6 +23,35 @@ with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 package cmd
 
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
 const (
-	terraform = "terraform"
+	exeSuffix = ""
 )
+
+// userDataDir returns the directory tfsw keeps user specific data that
+// isn't configuration in, following the XDG base directory specification.
+// That's ${XDG_DATA_HOME}/tfsw, or ${HOME}/.local/share/tfsw if it's
+// unset. On macOS, where the config directory is already in Application
+// Support, it's Application Support/tfsw-data unless ${XDG_DATA_HOME} is
+// set, so it's kept apart from the config directory
+func userDataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "tfsw"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Library", "Application Support", "tfsw-data"), nil
+	}
+
+	return filepath.Join(home, ".local", "share", "tfsw"), nil
+}
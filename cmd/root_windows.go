@@ -23,6 +23,24 @@ with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 package cmd
 
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
 const (
-	terraform = "terraform.exe"
+	exeSuffix = ".exe"
 )
+
+// userDataDir returns the directory tfsw keeps user specific data that
+// isn't configuration in, which is %LocalAppData%\tfsw so large files such
+// as installed versions aren't copied around with a roaming profile
+func userDataDir() (string, error) {
+	dir := os.Getenv("LocalAppData")
+	if dir == "" {
+		return "", errors.New("%LocalAppData% is not defined")
+	}
+
+	return filepath.Join(dir, "tfsw"), nil
+}
@@ -24,7 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"tfsw/internal/utils"
@@ -32,12 +32,11 @@ import (
 
 var (
 	selectCmd = &cobra.Command{
-		Args:              cobra.ExactValidArgs(1),
-		Long:              "Select the active Terraform version and install it if missing",
-		PreRun:            validateVersion,
+		Args:              cobra.MaximumNArgs(1),
+		Long:              "Select the active version of the selected product and install it if missing. A version can be exact, a constraint e.g. \"~> 1.5\", latest, latest:PREFIX, or latest-prerelease. Without a version, the version pinned by .terraform-version or .tool-versions is used, followed by the required_version of the Terraform configuration in the current directory",
 		Run:               selectRun,
 		Short:             "Select the active version",
-		Use:               "select VERSION...",
+		Use:               "select [VERSION]",
 		ValidArgsFunction: selectValidArgs,
 	}
 )
@@ -49,38 +48,74 @@ func init() {
 // selectRun is passed directly to the Cobra Run argument and executes
 // the primary logic for the `select` command
 func selectRun(cmd *cobra.Command, args []string) {
-	err := selectVersion(config.CurrentVersion, args[0])
-	switch err {
-	case ErrVersionSame:
-		fmt.Printf("Terraform %s already active!\n", args[0])
+	var expr string
+	if len(args) == 1 {
+		expr = args[0]
+	} else {
+		detected, src, err := detectVersion(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "No version given, and unable to detect one: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Using %s from %s\n", detected, src)
+		expr = detected
+	}
+
+	ver, err := resolveVersion(expr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to resolve %s: %v\n", expr, err)
+		os.Exit(1)
+	}
+
+	err = selectVersion(config.CurrentVersion, ver)
+	switch {
+	case errors.Is(err, ErrVersionSame):
+		fmt.Printf("%s %s already active!\n", config.Product.Title, ver)
 		os.Exit(0)
-	case nil:
-		fmt.Printf("Terraform %s is now active\n", args[0])
+	case err == nil:
+		fmt.Printf("%s %s is now active\n", config.Product.Title, ver)
 		os.Exit(0)
+	case errors.Is(err, utils.ErrChecksumMismatch):
+		fmt.Fprintf(os.Stderr, "%s %s has not been selected as the download is corrupt or has been tampered with: %v\n", config.Product.Title, ver, err)
+		os.Exit(1)
+	case errors.Is(err, utils.ErrNotSymlink):
+		fmt.Fprintf(os.Stderr, "%s %s has not been selected as %s is not a symlink, please move it out of the way\n", config.Product.Title, ver, config.SymlinkTarget)
+		os.Exit(1)
 	default:
 		// NOTE: This doesn't need a trailing \n to be set
-		fmt.Fprintf(os.Stderr, "Error setting Terraform version: %v", err)
+		fmt.Fprintf(os.Stderr, "Error setting %s version: %v", config.Product.Title, err)
 		os.Exit(1)
 	}
 }
 
 // selectVaildArgs only allows completion on the first argument as only
-// one argument is accepted
+// one argument is accepted. It offers the installed versions, followed by
+// any other versions in the cached release index
 func selectValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	if len(args) >= 1 {
 		return []string{}, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	validArgs := config.InstalledVersions
+	validArgs := config.InstalledVersions.strings()
+
+	// NOTE: Completion only ever reads the cached index so it stays
+	// fast, and works offline
+	if idx := cachedIndex(); idx != nil {
+		av := idx.available().without(config.InstalledVersions...)
+		sort.Sort(sort.Reverse(av))
+		validArgs = append(validArgs, av.strings()...)
+	}
 
 	return validArgs, cobra.ShellCompDirectiveNoFileComp
 }
 
 // selectVersion takes the current version, and a new version. If they're
 // the same it informs the user. If it's missing, it downloads it. Once the
-// version is available it updates the symlink
-func selectVersion(cur, new string) error {
-	if new == cur {
+// version is available it updates the symlink, or the default version when
+// in shim mode
+func selectVersion(cur, new *Version) error {
+	if new.Equal(cur) {
 		return ErrVersionSame
 	}
 
@@ -88,9 +123,25 @@ func selectVersion(cur, new string) error {
 		return err
 	}
 
-	tgt := filepath.Join(config.BinaryDirectory, terraform)
-	src := filepath.Join(config.ConfigDirectory, new, terraform)
-	if err := utils.Symlink(src, tgt); err != nil {
+	unlock, err := lockStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// NOTE: Another process may have deleted the version since it was
+	// installed
+	if _, err := os.Stat(config.Product.versionBinary(new)); err != nil {
+		return ErrVersionNotExist
+	}
+
+	// NOTE: In shim mode the symlink points at tfsw, so only the default
+	// version used when nothing else is pinned changes
+	if config.ShimMode {
+		return writeDefaultVersion(new)
+	}
+
+	if err := utils.Symlink(config.Product.versionBinary(new), config.SymlinkTarget); err != nil {
 		return err
 	}
 
@@ -0,0 +1,208 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed Terraform version number. Terraform versions are
+// semantic versions, optionally followed by a pre-release suffix made up
+// of a label and a number e.g. 1.6.0-beta2
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+
+	// Label is the pre-release label (alpha, beta, oci, or rc) and is
+	// empty for a final release
+	Label string
+
+	// Number is the pre-release number that follows the label, kept as
+	// a string as some pre-releases use a date e.g. alpha20211006
+	Number string
+
+	original string
+}
+
+// versions is a sortable collection of versions, ordered by precedence
+type versions []*Version
+
+// parseVersion parses a version number string into a Version
+func parseVersion(s string) (*Version, error) {
+	m := regex.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("%w: %s", ErrVersionInvalid, s)
+	}
+
+	v := &Version{Label: m[5], Number: m[6], original: s}
+
+	var err error
+	if v.Major, err = strconv.Atoi(m[1]); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrVersionInvalid, s)
+	}
+
+	if v.Minor, err = strconv.Atoi(m[2]); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrVersionInvalid, s)
+	}
+
+	if v.Patch, err = strconv.Atoi(m[3]); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrVersionInvalid, s)
+	}
+
+	return v, nil
+}
+
+// String returns the version as it was originally written
+func (v *Version) String() string {
+	if v.original != "" {
+		return v.original
+	}
+
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Label != "" {
+		s += "-" + v.Label + v.Number
+	}
+
+	return s
+}
+
+// Prerelease reports whether the version is a pre-release
+func (v *Version) Prerelease() bool {
+	return v.Label != ""
+}
+
+// Compare returns -1, 0, or 1 if v is lower than, equal to, or higher
+// than o. Versions are compared by their major, minor, and patch numbers,
+// then any pre-release has a lower precedence than the final release.
+// Pre-releases of the same version are ordered by their label, then the
+// number following it, so 1.6.0-beta2 < 1.6.0-beta10 < 1.6.0-rc1
+func (v *Version) Compare(o *Version) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+
+	if c := compareInt(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case v.Label == o.Label:
+		return compareNumeric(v.Number, o.Number)
+	case v.Label == "":
+		return 1
+	case o.Label == "":
+		return -1
+	}
+
+	return strings.Compare(v.Label, o.Label)
+}
+
+// Equal reports whether v and o are the same version
+func (v *Version) Equal(o *Version) bool {
+	if v == nil || o == nil {
+		return v == o
+	}
+
+	return v.Compare(o) == 0
+}
+
+// LessThan reports whether v has a lower precedence than o
+func (v *Version) LessThan(o *Version) bool {
+	return v.Compare(o) < 0
+}
+
+// compareInt returns -1, 0, or 1 if a is lower than, equal to, or higher
+// than b
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// compareNumeric compares two strings of digits by their numeric value
+// without converting them, as they may not fit in an int. An empty
+// string is treated as zero
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+
+	if c := compareInt(len(a), len(b)); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a, b)
+}
+
+func (vs versions) Len() int           { return len(vs) }
+func (vs versions) Less(i, j int) bool { return vs[i].LessThan(vs[j]) }
+func (vs versions) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
+
+// index searches for a version and returns its index. It returns -1 if
+// the version is not found
+func (vs versions) index(v *Version) int {
+	for i := range vs {
+		if vs[i].Equal(v) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// contains reports whether the version is in the collection
+func (vs versions) contains(v *Version) bool {
+	return vs.index(v) != -1
+}
+
+// without returns a new collection with every occurrence of the given
+// versions removed, with the order preserved
+func (vs versions) without(rm ...*Version) versions {
+	out := make(versions, 0, len(vs))
+	for _, v := range vs {
+		if !versions(rm).contains(v) {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+// strings returns the versions as strings, preserving their order
+func (vs versions) strings() []string {
+	out := make([]string, 0, len(vs))
+	for _, v := range vs {
+		out = append(out, v.String())
+	}
+
+	return out
+}
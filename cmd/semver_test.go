@@ -0,0 +1,153 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Version
+		invalid bool
+	}{
+		{in: "1.5.7", want: Version{Major: 1, Minor: 5, Patch: 7}},
+		{in: "0.12.31", want: Version{Major: 0, Minor: 12, Patch: 31}},
+		{in: "1.6.0-beta2", want: Version{Major: 1, Minor: 6, Patch: 0, Label: "beta", Number: "2"}},
+		{in: "1.1.0-alpha20211006", want: Version{Major: 1, Minor: 1, Patch: 0, Label: "alpha", Number: "20211006"}},
+		{in: "1.6.0-rc1", want: Version{Major: 1, Minor: 6, Patch: 0, Label: "rc", Number: "1"}},
+		{in: "1.5", invalid: true},
+		{in: "latest", invalid: true},
+		{in: "", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			v, err := parseVersion(tt.in)
+			if tt.invalid {
+				if !errors.Is(err, ErrVersionInvalid) {
+					t.Fatalf("parseVersion(%q) error = %v, want ErrVersionInvalid", tt.in, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseVersion(%q) error = %v", tt.in, err)
+			}
+
+			if v.Major != tt.want.Major || v.Minor != tt.want.Minor || v.Patch != tt.want.Patch || v.Label != tt.want.Label || v.Number != tt.want.Number {
+				t.Errorf("parseVersion(%q) = %+v, want %+v", tt.in, *v, tt.want)
+			}
+
+			if v.String() != tt.in {
+				t.Errorf("String() = %q, want %q", v.String(), tt.in)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.5.7", b: "1.5.7", want: 0},
+		{a: "1.5.7", b: "1.6.0", want: -1},
+		{a: "1.10.0", b: "1.9.9", want: 1},
+		{a: "2.0.0", b: "1.99.99", want: 1},
+		{a: "1.6.0-rc1", b: "1.6.0", want: -1},
+		{a: "1.6.0", b: "1.6.0-rc1", want: 1},
+		{a: "1.6.0-rc1", b: "1.5.7", want: 1},
+		{a: "1.6.0-alpha1", b: "1.6.0-beta1", want: -1},
+		{a: "1.6.0-beta1", b: "1.6.0-rc1", want: -1},
+		{a: "1.6.0-beta2", b: "1.6.0-beta10", want: -1},
+		{a: "1.6.0-beta10", b: "1.6.0-beta2", want: 1},
+		{a: "1.6.0-beta02", b: "1.6.0-beta2", want: 0},
+		{a: "1.1.0-alpha20211006", b: "1.1.0-alpha20211020", want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			a, err := parseVersion(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := parseVersion(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("Compare() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareNumeric(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "", b: "0", want: 0},
+		{a: "", b: "1", want: -1},
+		{a: "9", b: "10", want: -1},
+		{a: "010", b: "10", want: 0},
+		{a: "20211006", b: "3", want: 1},
+		{a: "99999999999999999999999", b: "99999999999999999999998", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := compareNumeric(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareNumeric(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionsSort(t *testing.T) {
+	in := []string{"1.6.0", "1.6.0-rc1", "0.12.31", "1.6.0-beta10", "1.5.7", "1.6.0-beta2", "1.6.0-alpha20230616"}
+	want := []string{"0.12.31", "1.5.7", "1.6.0-alpha20230616", "1.6.0-beta2", "1.6.0-beta10", "1.6.0-rc1", "1.6.0"}
+
+	var vs versions
+	for _, s := range in {
+		v, err := parseVersion(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs = append(vs, v)
+	}
+
+	sort.Sort(vs)
+
+	got := vs.strings()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sorted = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	projectConfigFileName string = ".tfsw.toml"
+	verifyChecksum        string = "checksum"
+	verifySignature       string = "signature"
+)
+
+var (
+	// settings are the top level keys of the config file. Each can be
+	// overridden by its environment variable, then its flag if it has one
+	settings = []setting{
+		pathSetting("bin_dir", "TFSW_BIN_DIR", "bin-dir", func(c *configuration) *string { return &c.BinaryDirectory }),
+		pathSetting("ca_bundle", "TFSW_CA_BUNDLE", "ca-bundle", func(c *configuration) *string { return &c.CABundle }),
+		pathSetting("cache_dir", "TFSW_CACHE_DIR", "cache-dir", func(c *configuration) *string { return &c.CacheDirectory }),
+		projectSetting(durationSetting("cache_ttl", "TFSW_CACHE_TTL", "cache-ttl", func(c *configuration) *time.Duration { return &c.CacheTTL })),
+		pathSetting("client_cert", "TFSW_CLIENT_CERT", "client-cert", func(c *configuration) *string { return &c.ClientCert }),
+		pathSetting("client_key", "TFSW_CLIENT_KEY", "client-key", func(c *configuration) *string { return &c.ClientKey }),
+		stringSetting("index_url", "TFSW_INDEX_URL", "", func(c *configuration) *string { return &c.IndexURL }),
+		pathSetting("keyring", "TFSW_KEYRING", "keyring", func(c *configuration) *string { return &c.KeyRingPath }),
+		projectSetting(durationSetting("lock_timeout", "TFSW_LOCK_TIMEOUT", "lock-timeout", func(c *configuration) *time.Duration { return &c.LockTimeout })),
+		{
+			env:  "TFSW_MIRROR",
+			flag: "mirror",
+			key:  "mirror",
+			set: func(c *configuration, v string) error {
+				if v != "" {
+					if err := validateRepositoryURL(v); err != nil {
+						return err
+					}
+				}
+				c.RepositoryURL = v
+				return nil
+			},
+			value: func(c *configuration) string { return c.RepositoryURL },
+		},
+		pathSetting("netrc", "NETRC", "netrc", func(c *configuration) *string { return &c.NetrcPath }),
+		projectSetting(stringSetting("product", "TFSW_PRODUCT", "product", func(c *configuration) *string { return &c.ProductName })),
+		pathSetting("store_dir", "TFSW_STORE_DIR", "store-dir", func(c *configuration) *string { return &c.StoreDirectory }),
+		stringSetting("url_template", "TFSW_URL_TEMPLATE", "url-template", func(c *configuration) *string { return &c.URLTemplate }),
+		{
+			env:  "TFSW_VERIFY",
+			flag: "verify",
+			key:  "verify",
+			set: func(c *configuration, v string) error {
+				if v != verifySignature && v != verifyChecksum {
+					return fmt.Errorf("must be %s or %s, not %q", verifySignature, verifyChecksum, v)
+				}
+				c.Verify = v
+				return nil
+			},
+			value: func(c *configuration) string { return c.Verify },
+		},
+	}
+)
+
+// setting is a top level key of the config file, along with the
+// environment variable and flag that override it. Only settings marked
+// project can be set in a project file
+type setting struct {
+	env     string
+	flag    string
+	key     string
+	project bool
+	set     func(c *configuration, v string) error
+	value   func(c *configuration) string
+}
+
+// projectSetting marks a setting as safe to take from a project file
+func projectSetting(s setting) setting {
+	s.project = true
+	return s
+}
+
+// stringSetting returns a setting stored as is in a string field
+func stringSetting(key, env, flag string, field func(c *configuration) *string) setting {
+	return setting{
+		env:  env,
+		flag: flag,
+		key:  key,
+		set: func(c *configuration, v string) error {
+			*field(c) = v
+			return nil
+		},
+		value: func(c *configuration) string { return *field(c) },
+	}
+}
+
+// pathSetting returns a setting for a path, where a leading ~ is expanded
+// to the home directory
+func pathSetting(key, env, flag string, field func(c *configuration) *string) setting {
+	s := stringSetting(key, env, flag, field)
+	s.set = func(c *configuration, v string) error {
+		*field(c) = expandHome(c, v)
+		return nil
+	}
+
+	return s
+}
+
+// durationSetting returns a setting for a duration e.g. 1h30m
+func durationSetting(key, env, flag string, field func(c *configuration) *time.Duration) setting {
+	return setting{
+		env:  env,
+		flag: flag,
+		key:  key,
+		set: func(c *configuration, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%q is not a valid duration", v)
+			}
+			*field(c) = d
+			return nil
+		},
+		value: func(c *configuration) string { return field(c).String() },
+	}
+}
+
+// lookupSetting returns the setting for a top level config file key
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+
+	return setting{}, false
+}
+
+// expandHome replaces a leading ~ in a path with the home directory
+func expandHome(c *configuration, p string) string {
+	if p == "~" {
+		return c.HomeDirectory
+	}
+
+	if strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		return filepath.Join(c.HomeDirectory, p[2:])
+	}
+
+	return p
+}
+
+// userOnlyKey reports whether a key may only be set in the user's config
+// file. A project file may come from a repository checked out from
+// anywhere, so it isn't trusted with anything that changes where releases
+// come from, how they're verified, where credentials are sent, or where
+// tfsw writes. That leaves the product, timeouts, and how products are
+// named and pinned
+func userOnlyKey(key string) bool {
+	if strings.HasPrefix(key, "products.") {
+		switch key[strings.LastIndex(key, ".")+1:] {
+		case "release_notes_url", "title", "tool_name", "version_file":
+			return false
+		}
+		return true
+	}
+
+	s, ok := lookupSetting(key)
+	return !ok || !s.project
+}
+
+// checkConfigKey returns an error if a key isn't one tfsw understands.
+// Product settings are checked when the products are defined
+func checkConfigKey(key string) error {
+	if strings.HasPrefix(key, "products.") {
+		return nil
+	}
+
+	if _, ok := lookupSetting(key); !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+
+	return nil
+}
+
+// findProjectFile walks from dir up to the root of the filesystem looking
+// for a project config file. It returns an empty string if there isn't one
+func findProjectFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		f := filepath.Join(dir, projectConfigFileName)
+		if _, err := os.Stat(f); err == nil {
+			return f, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// configFile reads the user's config file from the config directory, and
+// the project config file from the current directory or one of its
+// parents, if there are any. File holds the values from both, with the
+// project file taking precedence
+func (c *configuration) configFile() error {
+	// NOTE: Both files are found before either is read, so `config set`
+	// can still write to them if one is broken
+	c.UserFile = filepath.Join(c.ConfigDirectory, configFileName)
+	if wd, err := os.Getwd(); err == nil {
+		if c.ProjectFile, err = findProjectFile(wd); err != nil {
+			return err
+		}
+	}
+
+	user, err := readConfigFile(c.UserFile)
+	if err != nil {
+		return err
+	}
+
+	for k := range user {
+		if err := checkConfigKey(k); err != nil {
+			return fmt.Errorf("%s: %v", c.UserFile, err)
+		}
+	}
+
+	project := map[string]string{}
+
+	if c.ProjectFile != "" {
+		if project, err = readConfigFile(c.ProjectFile); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(project))
+		for k := range project {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if err := checkConfigKey(k); err != nil {
+				return fmt.Errorf("%s: %v", c.ProjectFile, err)
+			}
+
+			// NOTE: The file may have come with a cloned repository, so
+			// rather than stopping every command run below it, including
+			// the shim, anything it isn't trusted to set is ignored
+			if userOnlyKey(k) {
+				fmt.Fprintf(os.Stderr, "Ignoring %s in %s, it can only be set in %s\n", k, c.ProjectFile, c.UserFile)
+				delete(project, k)
+			}
+		}
+	}
+
+	c.File = map[string]string{}
+	for _, values := range []map[string]string{user, project} {
+		for k, v := range values {
+			c.File[k] = v
+		}
+	}
+
+	c.ProjectValues, c.UserValues = project, user
+	return nil
+}
+
+// applySettings applies each setting from the config files and environment,
+// in order of precedence. Flags are applied later by Cobra
+func (c *configuration) applySettings() error {
+	for _, s := range settings {
+		v, from, ok := c.lookup(s)
+		if !ok {
+			continue
+		}
+
+		if err := s.set(c, v); err != nil {
+			return fmt.Errorf("%s: %v", from, err)
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the value of a setting from the first of the environment,
+// the project file, and the user file, along with where it came from
+func (c *configuration) lookup(s setting) (string, string, bool) {
+	if s.env != "" {
+		if v, ok := os.LookupEnv(s.env); ok {
+			return v, s.env, true
+		}
+	}
+
+	if v, ok := c.ProjectValues[s.key]; ok {
+		return v, c.ProjectFile + ": " + s.key, true
+	}
+
+	if v, ok := c.UserValues[s.key]; ok {
+		return v, c.UserFile + ": " + s.key, true
+	}
+
+	return "", "", false
+}
+
+// fileKeys returns the keys set in either config file that aren't top
+// level settings, such as product settings, sorted
+func (c *configuration) fileKeys() []string {
+	var keys []string
+	for k := range c.File {
+		if _, ok := lookupSetting(k); !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
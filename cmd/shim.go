@@ -0,0 +1,271 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"tfsw/internal/utils"
+)
+
+const (
+	defaultVersionFile string = "default-version"
+)
+
+var (
+	shimCmd = &cobra.Command{
+		Long:  "Manage shim mode. In shim mode the product's symlink, e.g. terraform, points at tfsw itself, which picks the version to run each time it's called, rather than one version being active everywhere",
+		Short: "Manage shim mode",
+		Use:   "shim",
+	}
+	shimDisableCmd = &cobra.Command{
+		Args:  cobra.NoArgs,
+		Long:  "Point the product's symlink back at the default version, so one version is active everywhere",
+		Run:   shimDisableRun,
+		Short: "Disable shim mode",
+		Use:   "disable",
+	}
+	shimEnableCmd = &cobra.Command{
+		Args: cobra.NoArgs,
+		Long: `Point the product's symlink at tfsw, which then picks the version each time it's called from, in order:
+
+  1. The TFSW_<PRODUCT>_VERSION environment variable e.g. TFSW_TERRAFORM_VERSION
+  2. A .terraform-version (.opentofu-version for OpenTofu) or .tool-versions file in the current directory or its parents
+  3. The required_version of the configuration in the current directory
+  4. The default version, set with select`,
+		Run:   shimEnableRun,
+		Short: "Enable shim mode",
+		Use:   "enable",
+	}
+)
+
+func init() {
+	// Adds shim as a child command of tfsw, with its own child commands
+	rootCmd.AddCommand(shimCmd)
+	shimCmd.AddCommand(shimDisableCmd)
+	shimCmd.AddCommand(shimEnableCmd)
+}
+
+// shimEnableRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `shim enable` command
+func shimEnableRun(cmd *cobra.Command, args []string) {
+	if config.ShimMode {
+		fmt.Println("Shim mode is already enabled")
+		os.Exit(0)
+	}
+
+	if err := enableShim(config.CurrentVersion); err != nil {
+		fmt.Fprintf(os.Stderr, "Error enabling shim mode: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Shim mode enabled, %s now runs the version chosen for each directory\n", config.SymlinkTarget)
+	os.Exit(0)
+}
+
+// shimDisableRun is passed directly to the Cobra Run argument and executes
+// the primary logic for the `shim disable` command
+func shimDisableRun(cmd *cobra.Command, args []string) {
+	if !config.ShimMode {
+		fmt.Println("Shim mode is already disabled")
+		os.Exit(0)
+	}
+
+	err := disableShim(config.CurrentVersion)
+	switch err {
+	case ErrNoDefaultVersion:
+		fmt.Fprintln(os.Stderr, "No default version has been selected, please select one before disabling shim mode")
+		os.Exit(1)
+	case nil:
+		fmt.Printf("Shim mode disabled, %s %s is now active\n", config.Product.Title, config.CurrentVersion)
+		os.Exit(0)
+	default:
+		fmt.Fprintf(os.Stderr, "Error disabling shim mode: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// enableShim records the active version as the default, then points the
+// product's symlink at the running tfsw executable
+func enableShim(cur *Version) error {
+	unlock, err := lockStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if cur != nil {
+		if err := writeDefaultVersion(cur); err != nil {
+			return err
+		}
+	}
+
+	exe, err := executable()
+	if err != nil {
+		return err
+	}
+
+	return utils.Symlink(exe, config.SymlinkTarget)
+}
+
+// disableShim points the product's symlink back at the default version
+func disableShim(def *Version) error {
+	if def == nil {
+		return ErrNoDefaultVersion
+	}
+
+	unlock, err := lockStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return utils.Symlink(config.Product.versionBinary(def), config.SymlinkTarget)
+}
+
+// shimProduct returns the product tfsw has been called as through its
+// symlink, or nil if it's been called as itself
+func shimProduct() *product {
+	name := strings.TrimSuffix(basename, ".exe")
+	for _, p := range products {
+		if p.Binary == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// shimConfig finishes loading the configuration when tfsw is called as a
+// product, where there are no flags to apply, and nothing to diagnose or
+// repair a broken configuration with
+func shimConfig(p *product) error {
+	if loadErr != nil {
+		return loadErr
+	}
+
+	config.ProductName = p.Name
+	if err := migrateStore(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: unable to move installed versions to %s: %v\n", basename, config.StoreDirectory, err)
+	}
+
+	if err := config.loadProduct(); err != nil {
+		return err
+	}
+
+	return configureClient()
+}
+
+// shimRun is the entrypoint when tfsw is called as a product e.g.
+// terraform. It picks the version for the current directory, installs it
+// if needed, and then runs it with the given arguments. It only returns
+// if the version can't be found or run
+func shimRun(args []string) error {
+	ver, err := shimVersion()
+	if err != nil {
+		return err
+	}
+
+	return execVersion(ver, args)
+}
+
+// shimVersion picks the version to run from the first of the environment,
+// a pin file, or required_version, falling back to the default version.
+// Installed versions are preferred so the network is only used when
+// nothing installed will do, in which case the version is installed
+func shimVersion() (*Version, error) {
+	expr, ok := os.LookupEnv(config.Product.versionEnv())
+	if !ok || expr == "" {
+		detected, _, err := detectVersion(".")
+		switch {
+		case err == nil:
+			expr = detected
+		case config.CurrentVersion != nil:
+			return config.CurrentVersion, nil
+		default:
+			return nil, fmt.Errorf("%w, and %v", ErrNoDefaultVersion, err)
+		}
+	}
+
+	offline := config.Offline
+	config.Offline = true
+	ver, err := resolveVersion(expr)
+	config.Offline = offline
+
+	if err == nil && config.InstalledVersions.contains(ver) {
+		return ver, nil
+	}
+
+	if ver, err = resolveVersion(expr); err != nil {
+		return nil, err
+	}
+
+	if err := newVersion(ver); err != nil && !errors.Is(err, ErrVersionExists) {
+		return nil, err
+	}
+
+	return ver, nil
+}
+
+// defaultVersionPath returns the location of the file recording the
+// default version of a product used in shim mode
+func defaultVersionPath(p *product) string {
+	return filepath.Join(p.storeDir(), defaultVersionFile)
+}
+
+// readDefaultVersion returns the default version of a product used in
+// shim mode, or nil if one hasn't been set
+func readDefaultVersion(p *product) (*Version, error) {
+	b, err := os.ReadFile(defaultVersionPath(p))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return parseVersion(strings.TrimSpace(string(b)))
+}
+
+// writeDefaultVersion sets the default version used in shim mode
+func writeDefaultVersion(ver *Version) error {
+	if err := os.MkdirAll(config.Product.storeDir(), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(defaultVersionPath(config.Product), []byte(ver.String()+"\n"), 0644)
+}
+
+// executable returns the path to the running tfsw executable, with any
+// symlinks resolved
+func executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(exe)
+}
@@ -0,0 +1,146 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShimProjectFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		ignored []string
+		check   func(t *testing.T)
+	}{
+		{
+			name:    "user-only keys are ignored",
+			src:     "mirror = \"https://mirror.example.com\"\nca_bundle = \"/nonexistent/ca.pem\"\nkeyring = \"/nonexistent/keys.asc\"\ncache_ttl = \"5m\"\n",
+			ignored: []string{"mirror", "ca_bundle", "keyring"},
+			check: func(t *testing.T) {
+				if config.RepositoryURL != "" {
+					t.Errorf("RepositoryURL = %q, want it unset", config.RepositoryURL)
+				}
+
+				if config.CABundle != "" || config.KeyRingPath != "" {
+					t.Errorf("CABundle = %q, KeyRingPath = %q, want them unset", config.CABundle, config.KeyRingPath)
+				}
+
+				if config.CacheTTL.String() != "5m0s" {
+					t.Errorf("CacheTTL = %s, want 5m0s from the project file", config.CacheTTL)
+				}
+			},
+		},
+		{
+			name:    "user-only product settings are ignored",
+			src:     "[products.terraform]\ntitle = \"Terraform (project)\"\nrepository_url = \"https://mirror.example.com\"\n",
+			ignored: []string{"products.terraform.repository_url"},
+			check: func(t *testing.T) {
+				if config.Product.Title != "Terraform (project)" {
+					t.Errorf("Title = %q, want it from the project file", config.Product.Title)
+				}
+
+				if config.Product.RepositoryURL != defaultRepositoryURL {
+					t.Errorf("RepositoryURL = %q, want %q", config.Product.RepositoryURL, defaultRepositoryURL)
+				}
+			},
+		},
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, savedBasename, savedLoadErr := config, basename, loadErr
+	savedProducts := map[string]*product{}
+	for n, p := range products {
+		savedProducts[n] = p
+	}
+	defer func() {
+		config, basename, loadErr = saved, savedBasename, savedLoadErr
+		products = savedProducts
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			for _, env := range []string{"HOME", "USERPROFILE"} {
+				t.Setenv(env, home)
+			}
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+			t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+			t.Setenv("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+			t.Setenv("AppData", filepath.Join(home, "AppData", "Roaming"))
+			t.Setenv("LocalAppData", filepath.Join(home, "AppData", "Local"))
+
+			project := filepath.Join(home, "project")
+			if err := os.MkdirAll(project, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(filepath.Join(project, projectConfigFileName), []byte(tt.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Chdir(project); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(wd)
+
+			products = map[string]*product{}
+			for n, p := range savedProducts {
+				products[n] = p
+			}
+
+			// NOTE: This is what Execute does when tfsw is called as
+			// terraform, which must still run in the project
+			basename = "terraform"
+			config = &configuration{}
+			loadErr = config.load()
+
+			p := shimProduct()
+			if p == nil {
+				t.Fatal("shimProduct() = nil, want terraform")
+			}
+
+			if err := shimConfig(p); err != nil {
+				t.Fatalf("shimConfig() error = %v", err)
+			}
+
+			for _, k := range tt.ignored {
+				if _, ok := config.ProjectValues[k]; ok {
+					t.Errorf("ProjectValues[%q] is set, want it ignored", k)
+				}
+			}
+
+			tt.check(t)
+
+			// NOTE: Commands such as list and select check the same
+			// configuration once their flags have been applied
+			if errs := checkConfig(false); len(errs) > 0 {
+				t.Errorf("checkConfig() = %v, want no errors", errs)
+			}
+		})
+	}
+}
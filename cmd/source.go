@@ -0,0 +1,232 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"tfsw/internal/utils"
+)
+
+// source is somewhere the files belonging to a release can be fetched
+// from
+type source interface {
+	// fetch copies a release file to dst
+	fetch(ver, file, dst string) error
+}
+
+// httpSource fetches release files from the configured repository. The
+// progress of each download is shown on progress when set, otherwise with
+// a progress bar
+type httpSource struct {
+	progress *progressLine
+}
+
+func (s httpSource) fetch(ver, file, dst string) error {
+	if s.progress == nil {
+		return utils.FetchUrl(artifactURL(ver, file), dst)
+	}
+
+	s.progress.start("downloading " + file)
+	return utils.FetchUrlProgress(artifactURL(ver, file), dst, s.progress)
+}
+
+// dirSource fetches release files from a local directory, which either
+// holds the files directly, or is laid out like the repository with a
+// directory per product and version
+type dirSource struct {
+	dir string
+}
+
+func (s dirSource) fetch(ver, file, dst string) error {
+	for _, src := range []string{
+		filepath.Join(s.dir, file),
+		filepath.Join(s.dir, config.Product.Name, ver, file),
+	} {
+		err := copyFile(src, dst)
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%s not found in %s", file, s.dir)
+}
+
+// fileSource fetches release files from explicitly given paths, as used
+// by `new --from-zip`
+type fileSource struct {
+	files map[string]string
+}
+
+func (s fileSource) fetch(ver, file, dst string) error {
+	src, ok := s.files[file]
+	if !ok {
+		return fmt.Errorf("%s not found: %w", file, os.ErrNotExist)
+	}
+
+	return copyFile(src, dst)
+}
+
+// zipSource returns the version of a release zip on disk, along with a
+// source for it, its SHA256SUMS, and the signature. Unless given, the
+// SHA256SUMS file is expected alongside the zip, and the signature
+// alongside the SHA256SUMS file
+func zipSource(zip, sums, sig string) (*Version, source, error) {
+	v, goos, goarch, ok := config.Product.parseArchiveName(filepath.Base(zip))
+	if !ok {
+		return nil, nil, fmt.Errorf("the file name doesn't match %s", config.Product.archiveTemplate())
+	}
+
+	ver, err := parseVersion(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if goos != runtime.GOOS || goarch != runtime.GOARCH {
+		return nil, nil, fmt.Errorf("the zip is for %s/%s, not %s/%s", goos, goarch, runtime.GOOS, runtime.GOARCH)
+	}
+
+	name := config.Product.sumsName(ver.String())
+	if sums == "" {
+		sums = filepath.Join(filepath.Dir(zip), name)
+	}
+
+	if sig == "" {
+		sig = sums + config.Product.SignatureSuffix
+	}
+
+	return ver, fileSource{files: map[string]string{
+		filepath.Base(zip):                    zip,
+		name:                                  sums,
+		name + config.Product.SignatureSuffix: sig,
+	}}, nil
+}
+
+// releaseSource returns the source for the configured repository, which
+// is a local directory when the mirror is a path or file:// URL
+func releaseSource() source {
+	if dir, ok := localRepository(); ok {
+		return dirSource{dir: dir}
+	}
+
+	return httpSource{}
+}
+
+// localRepository returns the directory the configured repository points
+// at, if it's a path or file:// URL rather than a remote repository
+func localRepository() (string, bool) {
+	return localPath(repositoryURL())
+}
+
+// localPath returns the directory a path or file:// URL points at. It
+// reports false for anything else
+func localPath(s string) (string, bool) {
+	if filepath.IsAbs(s) {
+		return filepath.Clean(s), true
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+
+	p := filepath.FromSlash(u.Path)
+	if filepath.VolumeName(strings.TrimPrefix(u.Path, "/")) != "" {
+		// NOTE: file:///C:/releases is C:\releases on Windows
+		p = filepath.FromSlash(strings.TrimPrefix(u.Path, "/"))
+	}
+
+	return p, true
+}
+
+// localIndex builds the release index for a local directory. An
+// index.json is used if there is one, otherwise the index is made up from
+// the names of the release archives found
+func localIndex(dir string) (*releaseIndex, error) {
+	for _, f := range []string{
+		filepath.Join(dir, "index.json"),
+		filepath.Join(dir, config.Product.Name, "index.json"),
+	} {
+		b, err := os.ReadFile(f)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		idx, err := parseIndex(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f, err)
+		}
+		return idx, nil
+	}
+
+	name := config.Product.Name
+	idx := &releaseIndex{Name: name, Versions: map[string]release{}}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		ver, goos, goarch, ok := config.Product.parseArchiveName(info.Name())
+		if info.IsDir() || !ok {
+			return nil
+		}
+
+		r := idx.Versions[ver]
+		r.Name, r.Version = name, ver
+		r.Builds = append(r.Builds, build{Arch: goarch, Filename: info.Name(), Name: name, OS: goos, Version: ver})
+		idx.Versions[ver] = r
+		return nil
+	})
+
+	return idx, err
+}
+
+// copyFile copies src to dst, replacing dst if it already exists
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
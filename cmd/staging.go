@@ -0,0 +1,151 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+const (
+	smokeTestTimeout time.Duration = 30 * time.Second
+	stagingMaxAge    time.Duration = time.Hour
+)
+
+// stagingRoot returns the directory installs are extracted into before
+// being moved into place. It lives inside the store so the
+// final rename never crosses filesystems, and its name can never be
+// mistaken for an installed version
+func stagingRoot() string {
+	return filepath.Join(config.StoreDirectory, ".staging")
+}
+
+// newStagingDir creates an empty staging directory for a version
+func newStagingDir(ver string) (string, error) {
+	if err := os.MkdirAll(stagingRoot(), 0755); err != nil {
+		return "", err
+	}
+
+	return os.MkdirTemp(stagingRoot(), ver+"-")
+}
+
+// cleanupStaging removes staging directories left behind by installs that
+// were interrupted. Only directories older than stagingMaxAge are removed
+// so an install running in another process isn't pulled from under it
+func cleanupStaging() error {
+	entries, err := os.ReadDir(stagingRoot())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		if time.Since(info.ModTime()) > stagingMaxAge {
+			if err := os.RemoveAll(filepath.Join(stagingRoot(), e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// stageBinary copies a release that's the executable itself, rather than
+// a zip, into a staging directory under the product's binary name
+func stageBinary(src, staged string) error {
+	dst := filepath.Join(staged, config.Product.binary())
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+
+	return os.Chmod(dst, 0755)
+}
+
+// verifyStaged checks a staged install contains a usable binary for the
+// product before it's moved into place. With --smoke-test the binary is
+// also run to make sure it starts
+func verifyStaged(dir string) error {
+	name := config.Product.binary()
+	bin := filepath.Join(dir, name)
+
+	info, err := os.Stat(bin)
+	if err != nil {
+		return fmt.Errorf("%w: %s is missing from the archive", ErrInstallInvalid, name)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s is not a regular file", ErrInstallInvalid, name)
+	}
+
+	// NOTE: Windows has no executable bit, it goes by the file extension
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%w: %s is not executable", ErrInstallInvalid, name)
+	}
+
+	if !config.SmokeTest {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), smokeTestTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, bin, "version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: `%s version` failed: %v\n%s", ErrInstallInvalid, name, err, out)
+	}
+
+	return nil
+}
+
+// commitStaged atomically moves a verified staged install into place. Any
+// partial install left at the destination by an older tfsw is replaced
+func commitStaged(staged, dst string) error {
+	// NOTE: Staging directories are created private to the user, but the
+	// install should be as readable as the binary inside it
+	if err := os.Chmod(staged, 0755); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dst); err == nil {
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(staged, dst)
+}
@@ -0,0 +1,140 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"tfsw/internal/utils"
+)
+
+var (
+	// hashicorpKey is the public key HashiCorp sign their releases with,
+	// see https://www.hashicorp.com/security
+	//go:embed hashicorp.asc
+	hashicorpKey []byte
+
+	// opentofuKey is the public key OpenTofu sign their releases with,
+	// see https://get.opentofu.org/opentofu.asc
+	//go:embed opentofu.asc
+	opentofuKey []byte
+)
+
+// keyRing returns the keys trusted to sign SHA256SUMS files. These are
+// the keys embedded for the product, e.g. the HashiCorp key, along with
+// any keys in the product's key ring from the config file, and the user
+// supplied key ring, so a rotated key can be trusted before tfsw is
+// updated
+func keyRing() (openpgp.EntityList, error) {
+	var kr openpgp.EntityList
+	if len(config.Product.Keys) > 0 {
+		var err error
+		if kr, err = utils.ReadKeyRing(config.Product.Keys); err != nil {
+			return nil, fmt.Errorf("unable to read the embedded %s key: %v", config.Product.Title, err)
+		}
+	}
+
+	for _, file := range []string{config.Product.KeyRingPath, config.KeyRingPath} {
+		if file == "" {
+			continue
+		}
+
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := utils.ReadKeyRing(b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key ring %s: %v", file, err)
+		}
+
+		kr = append(kr, keys...)
+	}
+
+	if len(kr) == 0 {
+		return nil, fmt.Errorf("%w: no keys are trusted to sign %s releases, add its signing key with --keyring", utils.ErrSignatureInvalid, config.Product.Title)
+	}
+
+	return kr, nil
+}
+
+// verifySums fetches the detached signature for a SHA256SUMS file and
+// checks it was signed by a trusted key. HashiCorp publish a signature
+// from their current key as SUMS.sig, and one per key as SUMS.KEYID.sig,
+// so if the current signature can't be verified the signature for each
+// trusted key is tried in turn. This keeps installs working while keys
+// are rotated. Other products publish a single signature, and products
+// that don't sign their releases at all are only checked against their
+// SHA256SUMS. The same goes for every product when verify is set to
+// checksum
+func verifySums(src source, ver, dir, sums string) error {
+	if config.Product.Unsigned || config.Verify == verifyChecksum {
+		return nil
+	}
+
+	kr, err := keyRing()
+	if err != nil {
+		return err
+	}
+
+	sigs := []string{sums + config.Product.SignatureSuffix}
+	if config.Product.KeyedSignatures {
+		for _, k := range kr {
+			sigs = append(sigs, sums+"."+strings.ToUpper(k.PrimaryKey.KeyIdShortString())+config.Product.SignatureSuffix)
+		}
+	}
+
+	sumsFile := filepath.Join(dir, sums)
+
+	var verr error
+	for i, sig := range sigs {
+		sigFile := filepath.Join(dir, sig)
+		if err := src.fetch(ver, sig, sigFile); err != nil {
+			// NOTE: Only the first signature is guaranteed to exist
+			if i == 0 {
+				verr = err
+			}
+			continue
+		}
+
+		_, err := utils.VerifySignature(kr, sumsFile, sigFile)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, utils.ErrSignatureInvalid) {
+			return err
+		}
+
+		if verr == nil || i == 0 {
+			verr = err
+		}
+	}
+
+	return verr
+}
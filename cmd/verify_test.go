@@ -0,0 +1,72 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"tfsw/internal/utils"
+)
+
+func TestEmbeddedKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{name: "hashicorp.asc", key: hashicorpKey},
+		{name: "opentofu.asc", key: opentofuKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := utils.ReadKeyRing(tt.key)
+			if err != nil {
+				t.Fatalf("ReadKeyRing() error = %v", err)
+			}
+
+			if len(keys) == 0 {
+				t.Fatalf("%s holds no keys, so releases signed with it can't be verified", tt.name)
+			}
+		})
+	}
+}
+
+func TestProductKeys(t *testing.T) {
+	for _, n := range productNames() {
+		p := products[n]
+		if p.Unsigned {
+			continue
+		}
+
+		t.Run(n, func(t *testing.T) {
+			keys, err := utils.ReadKeyRing(p.Keys)
+			if err != nil {
+				t.Fatalf("ReadKeyRing() error = %v", err)
+			}
+
+			// NOTE: Without an embedded key every install fails until the
+			// user supplies a key ring of their own
+			if len(keys) == 0 {
+				t.Fatalf("%s is signed, but no keys are embedded to verify it", p.Title)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	toolVersionsFile string = ".tool-versions"
+)
+
+// versionFile walks from dir up to the root of the filesystem looking for
+// a file pinning the version of the product. In each directory a tfenv
+// style .terraform-version, or the product's equivalent, is preferred over
+// an asdf style .tool-versions. It returns the pinned version expression
+// and the file it came from, or ErrNoVersionFile if there isn't one
+func versionFile(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		f := filepath.Join(dir, config.Product.VersionFile)
+		expr, err := readVersionFile(f)
+		if err == nil {
+			return expr, f, nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", fmt.Errorf("%s: %v", f, err)
+		}
+
+		f = filepath.Join(dir, toolVersionsFile)
+		expr, err = readToolVersions(f)
+		if err == nil {
+			return expr, f, nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrNoVersionFile) {
+			return "", "", fmt.Errorf("%s: %v", f, err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", ErrNoVersionFile
+		}
+		dir = parent
+	}
+}
+
+// readVersionFile reads a .terraform-version style file, which contains a
+// single version expression. Blank lines and comments are ignored
+func readVersionFile(file string) (string, error) {
+	lines, err := pinFileLines(file)
+	if err != nil {
+		return "", err
+	}
+
+	if len(lines) == 0 {
+		return "", fmt.Errorf("no version found")
+	}
+
+	return lines[0], nil
+}
+
+// readToolVersions reads an asdf .tool-versions file and returns the
+// version given for the product. Where asdf is given fallback versions,
+// only the first is used. It returns ErrNoVersionFile if the file doesn't
+// mention the product
+func readToolVersions(file string) (string, error) {
+	lines, err := pinFileLines(file)
+	if err != nil {
+		return "", err
+	}
+
+	tool := config.Product.ToolName
+	for _, l := range lines {
+		f := strings.Fields(l)
+		if f[0] != tool {
+			continue
+		}
+
+		if len(f) < 2 {
+			return "", fmt.Errorf("%s has no version", tool)
+		}
+
+		if f[1] == "system" || strings.HasPrefix(f[1], "ref:") || strings.HasPrefix(f[1], "path:") {
+			return "", fmt.Errorf("%s %s is not supported by %s", tool, f[1], basename)
+		}
+
+		return f[1], nil
+	}
+
+	return "", ErrNoVersionFile
+}
+
+// pinFileLines reads a version file and returns its lines with comments
+// and surrounding whitespace removed, skipping any that are left blank
+func pinFileLines(file string) ([]string, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if i := strings.Index(l, "#"); i != -1 {
+			l = l[:i]
+		}
+
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines, scanner.Err()
+}
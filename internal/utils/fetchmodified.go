@@ -0,0 +1,82 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrNotModified is returned by FetchIfModified when the server reports
+// the resource hasn't changed since the validators were issued
+var ErrNotModified = errors.New("not modified")
+
+// Validators holds the HTTP cache validators returned alongside a
+// response, so the same resource can later be requested conditionally
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// FetchIfModified performs a conditional GET of uri using any validators
+// from a previous response, and returns the body along with the new
+// validators. If the resource is unchanged it returns ErrNotModified
+func FetchIfModified(uri string, v Validators) ([]byte, Validators, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, v, err
+	}
+
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, v, fmt.Errorf("failed to GET %s got: %v", uri, err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, v, err
+		}
+
+		return body, Validators{
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+		}, nil
+	case http.StatusNotModified:
+		return nil, v, ErrNotModified
+	case http.StatusUnauthorized, http.StatusProxyAuthRequired:
+		return nil, v, fmt.Errorf("%s requires authentication, got HTTP %d", uri, res.StatusCode)
+	default:
+		return nil, v, fmt.Errorf("did not get HTTP 200, got %d instead", res.StatusCode)
+	}
+}
@@ -21,60 +21,289 @@ with this program.  If not, see <https://www.gnu.org/licenses/>.
 package utils
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
-	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// stallTimeout is how long a download may go without receiving any
+	// data before it's abandoned and retried
+	stallTimeout time.Duration = time.Minute
+)
+
+var (
+	// ErrNotFound is returned by FetchUrl when the file requested doesn't
+	// exist
+	ErrNotFound = errors.New("the file requested doesn't exist")
+
+	// PartialDirectory is where FetchUrl keeps partial downloads, named
+	// after the URL, so a download interrupted in one run is resumed by the
+	// next. Each is locked while it's being downloaded. When empty they're
+	// kept alongside the destination file
+	PartialDirectory string
 
-	"github.com/schollz/progressbar/v3"
+	// Retry is the policy FetchUrl uses for transient failures
+	Retry = RetryPolicy{
+		Attempts: 5,
+		Base:     time.Second,
+		Max:      30 * time.Second,
+	}
 )
 
+// RetryPolicy controls how often, and how quickly, a failed request is
+// retried. The delay doubles after each attempt, starting from Base and
+// never exceeding Max
+type RetryPolicy struct {
+	Attempts int
+	Base     time.Duration
+	Max      time.Duration
+}
+
+// delay returns how long to wait before the given retry, counting from
+// zero, with up to a quarter added at random so many clients retrying at
+// once don't all return at the same moment
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.Base
+	for i := 0; i < retry && d < p.Max; i++ {
+		d *= 2
+	}
+
+	if d > p.Max {
+		d = p.Max
+	}
+
+	return d + time.Duration(rand.Int63n(int64(d)/4+1))
+}
+
+// transientError is a failure worth retrying. after, when set, is how
+// long the server asked us to wait
+type transientError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// FetchUrl downloads uri to dstFile. The download is written to a .part
+// file, see PartialDirectory, which is only renamed into place once
+// complete. Connection failures, stalled downloads, 5xx and 429 responses
+// are retried with exponential backoff, and if part of the file has
+// already been downloaded, by this run or an earlier one, the rest is
+// requested with a Range header rather than starting again. The .part
+// file is kept if every attempt fails, unless another process was already
+// downloading the same URL
 func FetchUrl(uri, dstFile string) error {
-	u, err := url.Parse(uri)
+	return FetchUrlProgress(uri, dstFile, nil)
+}
+
+// FetchUrlProgress is FetchUrl, writing the data downloaded to progress
+// rather than a progress bar. A nil progress shows the usual progress bar
+func FetchUrlProgress(uri, dstFile string, progress Progress) error {
+	part, release, err := partFile(uri, dstFile)
 	if err != nil {
 		return err
 	}
+	defer release()
+
+	for attempt := 0; attempt < Retry.Attempts; attempt++ {
+		if attempt > 0 {
+			wait := Retry.delay(attempt - 1)
+
+			var terr *transientError
+			if errors.As(err, &terr) && terr.after > wait {
+				wait = terr.after
+			}
+
+			fmt.Fprintf(os.Stderr, "Retrying in %s: %v\n", wait.Round(time.Second), err)
+			time.Sleep(wait)
+		}
+
+		err = fetchPart(uri, part, progress)
+		if err == nil {
+			return os.Rename(part, dstFile)
+		}
+
+		var terr *transientError
+		if !errors.As(err, &terr) {
+			break
+		}
+	}
+
+	return err
+}
+
+// partFile returns the path the download of uri is written to until it's
+// complete, creating PartialDirectory if needed, and a function to call
+// once the download is over. The shared .part file for uri is locked
+// until then. If another process holds the lock, e.g. parallel CI jobs
+// installing the same version, a .part file only this process uses is
+// returned instead, which is removed once the download is over
+func partFile(uri, dstFile string) (string, func(), error) {
+	if PartialDirectory == "" {
+		return dstFile + ".part", func() {}, nil
+	}
+
+	if err := os.MkdirAll(PartialDirectory, 0755); err != nil {
+		return "", nil, err
+	}
+
+	sum := sha256.Sum256([]byte(uri))
+	name := filepath.Join(PartialDirectory, hex.EncodeToString(sum[:]))
+
+	l, err := AcquireLock(name+".lock", 0, nil)
+	switch {
+	case err == nil:
+		return name + ".part", func() { _ = l.Release() }, nil
+	case errors.Is(err, ErrLockTimeout):
+		part := fmt.Sprintf("%s.%d.part", name, os.Getpid())
+		os.Remove(part)
+		return part, func() { os.Remove(part) }, nil
+	}
+
+	return "", nil, err
+}
+
+// fetchPart makes a single attempt at downloading uri to part, resuming
+// from the end of part if it already holds some of the file
+func fetchPart(uri, part string, progress Progress) error {
+	var offset int64 = 0
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
 
-	_, err = http.Head(uri)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return fmt.Errorf("failed to HEAD %s got: %v\n", uri, err)
+		return err
 	}
 
-	res, err := http.Get(uri)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	res, err := httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to GET %s got: %v\n", uri, err)
+		return requestError(uri, err)
 	}
 	defer res.Body.Close()
 
-	switch res.StatusCode {
-	case http.StatusOK:
-		//dstFile := filepath.Join(dir, path.Base(url))
-		file, err := os.OpenFile(dstFile, os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			return fmt.Errorf("unable to open file handle: %v\n", err)
-		}
-		defer file.Close()
-
-		bar := progressbar.DefaultBytes(
-			-1,
-			"downloading",
-		)
-		_, err = io.Copy(io.MultiWriter(file, bar), res.Body)
-		if err != nil {
-			return fmt.Errorf("failed io.Copy: %v\n", err)
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case res.StatusCode == http.StatusOK:
+		// NOTE: The server ignored the Range header, or there wasn't one,
+		// so anything already downloaded is thrown away
+		flags |= os.O_TRUNC
+	case res.StatusCode == http.StatusPartialContent && strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		flags |= os.O_APPEND
+	case res.StatusCode == http.StatusPartialContent, res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// NOTE: The partial download doesn't line up with the file on the
+		// server, which may have changed, so start again
+		os.Remove(part)
+		return &transientError{err: fmt.Errorf("unable to resume %s, restarting the download", uri)}
+	case res.StatusCode == http.StatusNotFound, res.StatusCode == http.StatusForbidden:
+		// NOTE: S3, which hosts the releases, returns 403 for missing keys
+		return fmt.Errorf("%w: %s", ErrNotFound, uri)
+	case res.StatusCode == http.StatusUnauthorized, res.StatusCode == http.StatusProxyAuthRequired:
+		return fmt.Errorf("%s requires authentication, got HTTP %d", uri, res.StatusCode)
+	case res.StatusCode == http.StatusTooManyRequests, res.StatusCode >= 500:
+		return &transientError{
+			err:   fmt.Errorf("did not get HTTP 200, got %d instead", res.StatusCode),
+			after: retryAfter(res.Header.Get("Retry-After")),
 		}
+	default:
+		return fmt.Errorf("did not get HTTP 200, got %d instead", res.StatusCode)
+	}
+
+	file, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open file handle: %v", err)
+	}
+	defer file.Close()
+
+	bar := progress
+	if bar == nil {
+		bar = NewProgress("downloading")
+	}
 
+	// NOTE: The request is cancelled if no data arrives for stallTimeout,
+	// which unblocks the read below
+	stall := time.AfterFunc(stallTimeout, cancel)
+	defer stall.Stop()
+
+	body := &progressReader{r: res.Body, progress: func() { stall.Reset(stallTimeout) }}
+	if _, err = io.Copy(io.MultiWriter(file, bar), body); err != nil {
+		return &transientError{err: fmt.Errorf("download of %s interrupted: %v", uri, err)}
+	}
+
+	if progress == nil {
 		if err = bar.Clear(); err != nil {
-			return fmt.Errorf("failed to clear the progress bar: %v\n", err)
+			return fmt.Errorf("failed to clear the progress bar: %v", err)
 		}
+	}
 
-		return nil
-	case http.StatusForbidden:
-		return fmt.Errorf("the file requested doesn't exist on %s", u.Hostname())
-	default:
-		return fmt.Errorf("did not get HTTP 200, got %d instead", res.StatusCode)
+	return file.Close()
+}
+
+// requestError wraps an error from making a request as transient, unless
+// retrying can't help, such as when the server's certificate isn't trusted
+func requestError(uri string, err error) error {
+	err = fmt.Errorf("failed to GET %s got: %w", uri, err)
+
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+	)
+
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) || errors.As(err, &invalid) {
+		return err
+	}
+
+	return &transientError{err: err}
+}
+
+// retryAfter parses a Retry-After header given in seconds. Dates, and
+// anything unparsable, are ignored
+func retryAfter(h string) time.Duration {
+	s, err := strconv.Atoi(h)
+	if err != nil || s < 0 {
+		return 0
+	}
+
+	return time.Duration(s) * time.Second
+}
+
+// progressReader calls progress whenever data is read
+type progressReader struct {
+	r        io.Reader
+	progress func()
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.progress()
 	}
 
-	return nil
+	return n, err
 }
@@ -0,0 +1,90 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPartFile(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "terraform.zip")
+	uri := "https://releases.hashicorp.com/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip"
+
+	PartialDirectory = ""
+	part, release, err := partFile(uri, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	if part != dst+".part" {
+		t.Errorf("partFile() = %s, want %s", part, dst+".part")
+	}
+
+	PartialDirectory = filepath.Join(dir, "downloads")
+	defer func() { PartialDirectory = "" }()
+
+	shared, release, err := partFile(uri, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Dir(shared) != PartialDirectory || strings.Count(filepath.Base(shared), ".") != 1 {
+		t.Errorf("partFile() = %s, want a shared .part file in %s", shared, PartialDirectory)
+	}
+
+	// NOTE: While the shared .part file is locked, anyone else downloading
+	// the same URL gets a .part file of their own
+	private, releasePrivate, err := partFile(uri, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releasePrivate()
+
+	if private == shared {
+		t.Errorf("partFile() = %s while it's locked, want a different .part file", private)
+	}
+
+	other, releaseOther, err := partFile(uri+".sig", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseOther()
+
+	if other == shared || strings.Count(filepath.Base(other), ".") != 1 {
+		t.Errorf("partFile() = %s for another URL, want its own shared .part file", other)
+	}
+
+	release()
+
+	again, release, err := partFile(uri, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	if again != shared {
+		t.Errorf("partFile() = %s once unlocked, want %s", again, shared)
+	}
+}
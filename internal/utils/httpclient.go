@@ -0,0 +1,260 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// httpClient is used for every request. There's no overall timeout, as
+	// a release can take a long time to download on a slow connection,
+	// instead each stage of the request is limited and FetchUrl abandons
+	// a download that stalls
+	httpClient = &http.Client{Transport: newTransport()}
+)
+
+// ClientOptions configures the client built by NewClient. Proxies are
+// always taken from ${HTTPS_PROXY}, ${HTTP_PROXY}, and ${NO_PROXY}, with
+// any credentials for the proxy given in its URL
+type ClientOptions struct {
+	// CABundle is a PEM file of certificate authorities trusted alongside
+	// the system ones, for mirrors and proxies using a private CA
+	CABundle string
+
+	// ClientCert and ClientKey are a PEM certificate and key presented to
+	// servers that require mutual TLS
+	ClientCert string
+	ClientKey  string
+
+	// Netrc is a .netrc file holding credentials for each host. A missing
+	// file is ignored
+	Netrc string
+
+	// Token is sent as a bearer token to TokenHosts, in preference to any
+	// credentials in Netrc
+	Token      string
+	TokenHosts []string
+}
+
+// SetClient replaces the client used by FetchUrl and FetchIfModified
+func SetClient(c *http.Client) {
+	httpClient = c
+}
+
+// NewClient builds a client from the given options
+func NewClient(opts ClientOptions) (*http.Client, error) {
+	t := newTransport()
+	t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			// NOTE: The system pool isn't available on Windows before
+			// Go 1.18, so only the bundle is trusted there
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+		}
+
+		t.TLSClientConfig.RootCAs = pool
+	}
+
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, errors.New("a client certificate and key must be given together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+
+		t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	auth := &authTransport{base: t, token: opts.Token, tokenHosts: opts.TokenHosts}
+	if opts.Netrc != "" {
+		creds, err := ReadNetrc(opts.Netrc)
+		if err != nil {
+			return nil, err
+		}
+
+		auth.netrc = creds
+	}
+
+	return &http.Client{Transport: auth}, nil
+}
+
+// newTransport returns a transport with a timeout for each stage of a
+// request
+func newTransport() *http.Transport {
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			KeepAlive: 30 * time.Second,
+			Timeout:   30 * time.Second,
+		}).DialContext,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          10,
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+	}
+}
+
+// authTransport adds credentials to requests. They're looked up for each
+// request, including redirects, so are never sent to a host they weren't
+// given for
+type authTransport struct {
+	base       http.RoundTripper
+	netrc      map[string]NetrcCredentials
+	token      string
+	tokenHosts []string
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	host := req.URL.Hostname()
+	if t.token != "" && Index(t.tokenHosts, host) != -1 {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.token)
+		return t.base.RoundTrip(req)
+	}
+
+	c, ok := t.netrc[host]
+	if !ok {
+		c, ok = t.netrc[""]
+	}
+
+	if ok && c.Login != "" {
+		req = req.Clone(req.Context())
+		req.SetBasicAuth(c.Login, c.Password)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// NetrcCredentials are the login and password for a machine in a .netrc
+// file
+type NetrcCredentials struct {
+	Login    string
+	Password string
+}
+
+// ReadNetrc returns the credentials in a .netrc file by machine name, with
+// the default entry, if any, under "". A missing file has no credentials
+func ReadNetrc(file string) (map[string]NetrcCredentials, error) {
+	creds := map[string]NetrcCredentials{}
+
+	fh, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return creds, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var (
+		machine string
+		current *NetrcCredentials
+		macro   bool
+	)
+
+	save := func() {
+		if current != nil {
+			creds[machine] = *current
+		}
+	}
+
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// NOTE: A macro definition runs until the next blank line
+		if macro {
+			macro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		for i := 0; i < len(fields); i++ {
+			next := func() string {
+				if i+1 < len(fields) {
+					i++
+					return fields[i]
+				}
+				return ""
+			}
+
+			switch fields[i] {
+			case "machine":
+				save()
+				machine, current = next(), &NetrcCredentials{}
+			case "default":
+				save()
+				machine, current = "", &NetrcCredentials{}
+			case "login":
+				if v := next(); current != nil {
+					current.Login = v
+				}
+			case "password":
+				if v := next(); current != nil {
+					current.Password = v
+				}
+			case "account":
+				next()
+			case "macdef":
+				next()
+				macro = true
+				i = len(fields)
+			}
+		}
+	}
+	save()
+
+	return creds, scanner.Err()
+}
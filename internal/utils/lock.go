@@ -0,0 +1,113 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// lockPoll is how often a held lock is retried
+	lockPoll time.Duration = 100 * time.Millisecond
+)
+
+// ErrLockTimeout is returned by AcquireLock when the lock is still held by
+// another process once the timeout has passed
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// Lock is an advisory lock on a file, held until it's released or the
+// process exits
+type Lock struct {
+	file *os.File
+}
+
+// AcquireLock takes an exclusive lock on file, creating it if needed,
+// and records the PID of this process in it. If another process holds the
+// lock, waiting is called once with its PID, and the lock is retried
+// until timeout has passed
+func AcquireLock(file string, timeout time.Duration, waiting func(pid int)) (*Lock, error) {
+	fh, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	notified := false
+	for {
+		locked, err := tryLock(fh)
+		if err != nil {
+			fh.Close()
+			return nil, err
+		}
+
+		if locked {
+			break
+		}
+
+		if !notified && waiting != nil {
+			waiting(lockHolder(fh))
+			notified = true
+		}
+
+		if time.Now().After(deadline) {
+			pid := lockHolder(fh)
+			fh.Close()
+			return nil, fmt.Errorf("%w %s held by PID %d", ErrLockTimeout, file, pid)
+		}
+
+		time.Sleep(lockPoll)
+	}
+
+	if err := fh.Truncate(0); err == nil {
+		_, _ = fh.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &Lock{file: fh}, nil
+}
+
+// Release gives up the lock
+func (l *Lock) Release() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
+
+// lockHolder returns the PID recorded in a lock file, or 0 if there isn't
+// one
+func lockHolder(fh *os.File) int {
+	b := make([]byte, 32)
+	n, _ := fh.ReadAt(b, 0)
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b[:n])))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
@@ -0,0 +1,54 @@
+//go:build windows
+// +build windows
+
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// NOTE: The lock covers a byte range beyond the end of the file, as a
+// locked range can't be read by other processes and the PID of the holder
+// is stored at the start
+var lockRange = windows.Overlapped{OffsetHigh: 1}
+
+// tryLock takes an exclusive lock on fh with LockFileEx without blocking,
+// reporting whether it was taken
+func tryLock(fh *os.File) (bool, error) {
+	ol := lockRange
+	err := windows.LockFileEx(windows.Handle(fh.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// unlock releases the lock on fh
+func unlock(fh *os.File) error {
+	ol := lockRange
+	return windows.UnlockFileEx(windows.Handle(fh.Fd()), 0, 1, 0, &ol)
+}
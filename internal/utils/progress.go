@@ -0,0 +1,56 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"io"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+var (
+	// Discard is a Progress that shows nothing
+	Discard Progress = discard{}
+
+	// NewProgress returns the Progress shown while downloading or
+	// extracting, described by desc. It's a progress bar by default, and
+	// can be replaced to show progress some other way, or not at all
+	NewProgress = func(desc string) Progress {
+		return progressbar.DefaultBytes(-1, desc)
+	}
+)
+
+// Progress is written the data downloaded or extracted, and cleared once
+// it's complete
+type Progress interface {
+	io.Writer
+	Clear() error
+}
+
+type discard struct{}
+
+func (discard) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (discard) Clear() error {
+	return nil
+}
@@ -25,13 +25,35 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// TODO - Verify SHA256SUMS against GPG signature
+// ErrChecksumMismatch is wrapped by ChecksumError, so callers can check
+// for a mismatch with errors.Is
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumError is returned by Sha256sum when a file's hash doesn't match
+// the hash recorded for it in the SHA256SUMS file
+type ChecksumError struct {
+	File     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: %s expected sha256 %s, got %s", ErrChecksumMismatch, e.File, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// Sha256sum checks the hash of file against the hash recorded for it in
+// sums. It returns a *ChecksumError if they don't match
 func Sha256sum(sums, file string) (bool, error) {
 	// TODO? - Emulate the coreutils sha256sum functionaliy where it will attempt to
 	// find and vaildate all files in the sumfile
@@ -68,7 +90,7 @@ func Sha256sum(sums, file string) (bool, error) {
 		return true, nil
 	}
 
-	return false, nil
+	return false, &ChecksumError{File: base, Expected: sum, Actual: sha256sum}
 }
 
 // TODO - Allow sha256.New() to be passed in as an argument so it can take in any hashing algo
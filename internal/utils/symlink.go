@@ -21,18 +21,61 @@ with this program.  If not, see <https://www.gnu.org/licenses/>.
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 )
 
+// ErrNotSymlink is returned by Symlink when the target exists and isn't a
+// symlink, so replacing it could destroy something that wasn't made by
+// tfsw
+var ErrNotSymlink = errors.New("not a symlink")
+
+// Symlink points the symlink tgt at src. The new link is created alongside
+// tgt and renamed over it, so tgt always points at either the old or the
+// new src, and never goes missing. If the switch fails the previous link
+// is put back
 func Symlink(src, tgt string) error {
+	info, err := os.Lstat(tgt)
+	switch {
+	case err == nil && info.Mode()&os.ModeSymlink == 0:
+		return fmt.Errorf("%w: %s exists, and won't be replaced", ErrNotSymlink, tgt)
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	old, _ := os.Readlink(tgt)
 
-	// TODO - Ignore path error but catch everything else
-	// TODO - Create a new function that removes the symlink if it exists
-	_ = os.Remove(tgt)
+	tmp := filepath.Join(filepath.Dir(tgt), fmt.Sprintf(".%s.%d.tmp", filepath.Base(tgt), os.Getpid()))
+	_ = os.Remove(tmp)
 
-	if err := os.Symlink(src, tgt); err != nil {
+	if err := os.Symlink(src, tmp); err != nil {
 		return err
 	}
 
+	if err := os.Rename(tmp, tgt); err != nil {
+		_ = os.Remove(tmp)
+		return restoreSymlink(old, tgt, err)
+	}
+
 	return nil
 }
+
+// restoreSymlink puts back the link tgt pointing at old, if it's gone
+// missing, and returns the error that caused the switch to fail
+func restoreSymlink(old, tgt string, cause error) error {
+	if old == "" {
+		return cause
+	}
+
+	if _, err := os.Lstat(tgt); !errors.Is(err, os.ErrNotExist) {
+		return cause
+	}
+
+	if err := os.Symlink(old, tgt); err != nil {
+		return fmt.Errorf("%v, and unable to restore the link to %s: %v", cause, old, err)
+	}
+
+	return cause
+}
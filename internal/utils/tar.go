@@ -0,0 +1,185 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Tar writes the regular files under the directory src into a new tar
+// archive at dst, with names relative to src. It returns the number of
+// files archived
+func Tar(src, dst string) (int, error) {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	tw := tar.NewWriter(out)
+
+	var archived int = 0
+	err = filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(tw, f); err != nil {
+			return err
+		}
+
+		archived++
+		return nil
+	})
+	if err != nil {
+		return archived, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return archived, err
+	}
+
+	return archived, out.Close()
+}
+
+// Untar extracts the regular files in the tar archive src into the
+// directory dst, applying the same checks as Unzip. As a tar archive can
+// only be read in order it's read twice, once to check every entry and
+// once to extract them. It returns the number of files extracted
+func Untar(src, dst string, limits ArchiveLimits) (int, error) {
+	if err := checkTar(src, dst, limits); err != nil {
+		return 0, err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	tr := tar.NewReader(in)
+	var unarchived int = 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return unarchived, err
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if err := untarFile(tr, hdr, filepath.Join(dst, filepath.FromSlash(hdr.Name))); err != nil {
+			return unarchived, err
+		}
+
+		unarchived++
+	}
+
+	return unarchived, nil
+}
+
+// checkTar checks every entry in the tar archive src against the limits
+func checkTar(src, dst string, limits ArchiveLimits) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tr := tar.NewReader(in)
+	var entries int = 0
+	var total int64 = 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		entries++
+		if limits.MaxEntries > 0 && entries > limits.MaxEntries {
+			return &ArchiveError{Reason: fmt.Sprintf("has more than %d entries", limits.MaxEntries)}
+		}
+
+		if err := checkEntry(hdr.Name, hdr.FileInfo().Mode(), dst, limits); err != nil {
+			return err
+		}
+
+		total += hdr.Size
+		if limits.MaxBytes > 0 && total > limits.MaxBytes {
+			return &ArchiveError{Entry: hdr.Name, Reason: fmt.Sprintf("takes the archive over the %d byte limit", limits.MaxBytes)}
+		}
+	}
+}
+
+// untarFile extracts the current entry of the archive to path
+func untarFile(r io.Reader, hdr *tar.Header, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	dstfile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer dstfile.Close()
+
+	// NOTE: The tar reader never returns more than the size in the header,
+	// which has already been checked against the limits
+	if _, err := io.Copy(dstfile, r); err != nil {
+		return err
+	}
+
+	return dstfile.Close()
+}
@@ -22,50 +22,169 @@ package utils
 
 import (
 	"archive/zip"
-	"github.com/schollz/progressbar/v3"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
-func Unzip(src, dst string) (int, error) {
+// ErrArchiveRejected is wrapped by ArchiveError, so callers can check for a
+// rejected archive with errors.Is
+var ErrArchiveRejected = errors.New("archive rejected")
+
+// ArchiveError is returned by Unzip and Untar when an archive, or an entry
+// in it, breaks one of the limits it was given
+type ArchiveError struct {
+	Entry  string
+	Reason string
+}
+
+func (e *ArchiveError) Error() string {
+	if e.Entry == "" {
+		return fmt.Sprintf("%s: %s", ErrArchiveRejected, e.Reason)
+	}
+
+	return fmt.Sprintf("%s: entry %q %s", ErrArchiveRejected, e.Entry, e.Reason)
+}
+
+func (e *ArchiveError) Unwrap() error {
+	return ErrArchiveRejected
+}
+
+// ArchiveLimits restricts what Unzip and Untar will extract. A zero value
+// disables the corresponding limit
+type ArchiveLimits struct {
+	// Allowed is the list of entry names that may be extracted
+	Allowed []string
+
+	// MaxBytes is the most data that may be extracted, across all
+	// entries
+	MaxBytes int64
+
+	// MaxEntries is the most entries the archive may contain
+	MaxEntries int
+}
+
+// Unzip extracts the regular files in the archive src into the directory
+// dst. Every entry is checked against the limits before anything is
+// written, and entries with names that would escape dst, symlinks, and
+// other special files are always rejected. It returns the number of files
+// extracted
+func Unzip(src, dst string, limits ArchiveLimits) (int, error) {
 	archive, err := zip.OpenReader(src)
 	if err != nil {
 		return 0, err
 	}
 	defer archive.Close()
 
-	var unarchived int = 0
+	if limits.MaxEntries > 0 && len(archive.File) > limits.MaxEntries {
+		return 0, &ArchiveError{Reason: fmt.Sprintf("has %d entries, the most allowed is %d", len(archive.File), limits.MaxEntries)}
+	}
+
+	var total uint64
 	for _, f := range archive.File {
-		path := filepath.Join(dst, f.Name)
+		if err := checkEntry(f.Name, f.Mode(), dst, limits); err != nil {
+			return 0, err
+		}
 
-		// TODO: If this fails it should clean up rather than
-		// leave a partically unarchived zip?
-		dstfile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return unarchived, err
+		total += f.UncompressedSize64
+		if limits.MaxBytes > 0 && total > uint64(limits.MaxBytes) {
+			return 0, &ArchiveError{Entry: f.Name, Reason: fmt.Sprintf("takes the archive over the %d byte limit", limits.MaxBytes)}
+		}
+	}
+
+	remaining := limits.MaxBytes
+	var unarchived int = 0
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
 		}
-		defer dstfile.Close()
 
-		srcfile, err := f.Open()
+		n, err := unzipFile(f, filepath.Join(dst, filepath.FromSlash(f.Name)), remaining, limits.MaxBytes > 0)
 		if err != nil {
 			return unarchived, err
 		}
-		defer srcfile.Close()
+		remaining -= n
 
-		bar := progressbar.DefaultBytes(
-			-1,
-			"extracting ",
-		)
+		unarchived++
+	}
 
-		if _, err := io.Copy(io.MultiWriter(dstfile, bar), srcfile); err != nil {
-			return unarchived, err
+	return unarchived, nil
+}
+
+// checkEntry rejects any entry that isn't a plain file or directory inside
+// dst, or isn't on the list of allowed names
+func checkEntry(name string, mode os.FileMode, dst string, limits ArchiveLimits) error {
+	if name == "" || strings.Contains(name, "\\") || path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return &ArchiveError{Entry: name, Reason: "has an absolute or invalid path"}
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return &ArchiveError{Entry: name, Reason: "escapes the destination directory"}
 		}
+	}
 
-		_ = bar.Clear()
+	rel, err := filepath.Rel(dst, filepath.Join(dst, filepath.FromSlash(name)))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &ArchiveError{Entry: name, Reason: "escapes the destination directory"}
+	}
 
-		unarchived++
+	if mode&os.ModeSymlink != 0 {
+		return &ArchiveError{Entry: name, Reason: "is a symlink"}
 	}
 
-	return unarchived, nil
+	if !mode.IsRegular() && !mode.IsDir() {
+		return &ArchiveError{Entry: name, Reason: "is not a regular file"}
+	}
+
+	if len(limits.Allowed) > 0 && !mode.IsDir() && Index(limits.Allowed, path.Clean(name)) == -1 {
+		return &ArchiveError{Entry: name, Reason: "is not an expected file"}
+	}
+
+	return nil
+}
+
+// unzipFile extracts a single entry to path, returning the number of bytes
+// written. When limited, it fails as soon as more than max bytes have been
+// written, as the size recorded in the archive can't be trusted
+func unzipFile(f *zip.File, path string, max int64, limited bool) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	dstfile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+	defer dstfile.Close()
+
+	srcfile, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer srcfile.Close()
+
+	var r io.Reader = srcfile
+	if limited {
+		r = io.LimitReader(srcfile, max+1)
+	}
+
+	bar := NewProgress("extracting ")
+
+	n, err := io.Copy(io.MultiWriter(dstfile, bar), r)
+	if err != nil {
+		return n, err
+	}
+
+	_ = bar.Clear()
+
+	if limited && n > max {
+		return n, &ArchiveError{Entry: f.Name, Reason: "is larger than recorded in the archive"}
+	}
+
+	return n, dstfile.Close()
 }
@@ -0,0 +1,187 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipEntry is a file to write to a test archive
+type zipEntry struct {
+	name string
+	body string
+	mode os.FileMode
+}
+
+// writeZip writes the entries to a zip archive in dir and returns its path
+func writeZip(t *testing.T, dir string, entries []zipEntry) string {
+	t.Helper()
+
+	file := filepath.Join(dir, "test.zip")
+	fh, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	w := zip.NewWriter(fh)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		mode := e.mode
+		if mode == 0 {
+			mode = 0755
+		}
+		h.SetMode(mode)
+
+		f, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestUnzip(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []zipEntry
+		limits   ArchiveLimits
+		want     int
+		rejected string
+	}{
+		{
+			name:    "files and directories",
+			entries: []zipEntry{{name: "terraform", body: "binary"}, {name: "docs/", mode: os.ModeDir | 0755}, {name: "docs/README.md", body: "readme"}},
+			want:    2,
+		},
+		{
+			name:    "allowed names",
+			entries: []zipEntry{{name: "terraform", body: "binary"}},
+			limits:  ArchiveLimits{Allowed: []string{"terraform"}},
+			want:    1,
+		},
+		{
+			name:     "parent directory",
+			entries:  []zipEntry{{name: "terraform", body: "binary"}, {name: "../escape", body: "evil"}},
+			rejected: "escapes the destination directory",
+		},
+		{
+			name:     "nested parent directory",
+			entries:  []zipEntry{{name: "docs/../../escape", body: "evil"}},
+			rejected: "escapes the destination directory",
+		},
+		{
+			name:     "absolute path",
+			entries:  []zipEntry{{name: "/tmp/escape", body: "evil"}},
+			rejected: "has an absolute or invalid path",
+		},
+		{
+			name:     "backslash",
+			entries:  []zipEntry{{name: `..\escape`, body: "evil"}},
+			rejected: "has an absolute or invalid path",
+		},
+		{
+			name:     "symlink",
+			entries:  []zipEntry{{name: "terraform", body: "/etc/passwd", mode: os.ModeSymlink | 0777}},
+			rejected: "is a symlink",
+		},
+		{
+			name:     "not allowed",
+			entries:  []zipEntry{{name: "terraform", body: "binary"}, {name: "extra", body: "extra"}},
+			limits:   ArchiveLimits{Allowed: []string{"terraform"}},
+			rejected: "is not an expected file",
+		},
+		{
+			name:     "too many entries",
+			entries:  []zipEntry{{name: "a"}, {name: "b"}, {name: "c"}},
+			limits:   ArchiveLimits{MaxEntries: 2},
+			rejected: "has 3 entries",
+		},
+		{
+			name:     "too large",
+			entries:  []zipEntry{{name: "a", body: strings.Repeat("a", 8)}, {name: "b", body: strings.Repeat("b", 8)}},
+			limits:   ArchiveLimits{MaxBytes: 10},
+			rejected: "byte limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := writeZip(t, dir, tt.entries)
+			dst := filepath.Join(dir, "out")
+
+			n, err := Unzip(src, dst, tt.limits)
+			if tt.rejected != "" {
+				if !errors.Is(err, ErrArchiveRejected) || !strings.Contains(err.Error(), tt.rejected) {
+					t.Fatalf("Unzip() error = %v, want %q", err, tt.rejected)
+				}
+
+				// NOTE: Every entry is checked before anything is written
+				if _, err := os.Stat(dst); !os.IsNotExist(err) {
+					t.Errorf("Unzip() wrote to %s before rejecting the archive", dst)
+				}
+
+				if _, err := os.Stat(filepath.Join(dir, "escape")); !os.IsNotExist(err) {
+					t.Errorf("Unzip() wrote outside %s", dst)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unzip() error = %v", err)
+			}
+
+			if n != tt.want {
+				t.Errorf("Unzip() = %d, want %d", n, tt.want)
+			}
+
+			for _, e := range tt.entries {
+				if strings.HasSuffix(e.name, "/") {
+					continue
+				}
+
+				b, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(e.name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(b) != e.body {
+					t.Errorf("%s = %q, want %q", e.name, b, e.body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Terraform Switch - A commandline utility to manage multiple versions
+of HashiCorps infrastructure as code tool, Terraform
+
+Copyright (C) 2022  Tom Cole <tom@m33x-7.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along
+with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// ErrSignatureInvalid is returned by VerifySignature when the signature
+// wasn't made by any of the trusted keys, or doesn't match the file
+var ErrSignatureInvalid = errors.New("signature verification failed")
+
+// ReadKeyRing parses an OpenPGP key ring in either armored or binary form,
+// as exported by `gpg --export [--armor]`
+func ReadKeyRing(b []byte) (openpgp.EntityList, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	}
+
+	return openpgp.ReadKeyRing(bytes.NewReader(b))
+}
+
+// VerifySignature checks that sig is a detached OpenPGP signature of file
+// made by one of the keys in keyring. The signature can be armored or
+// binary. It returns the key that made the signature
+func VerifySignature(keyring openpgp.EntityList, file, sig string) (*openpgp.Entity, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := os.ReadFile(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	var signer *openpgp.Entity
+	if bytes.HasPrefix(bytes.TrimSpace(s), []byte("-----BEGIN")) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, f, bytes.NewReader(s))
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keyring, f, bytes.NewReader(s))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w for %s: %v", ErrSignatureInvalid, file, err)
+	}
+
+	return signer, nil
+}
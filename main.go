@@ -1,9 +1,14 @@
 package main
 
 import (
+	"os"
+
 	"tfsw/cmd"
 )
 
 func main() {
-	cmd.Execute()
+	// NOTE: Execute reports any error itself before returning it
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }